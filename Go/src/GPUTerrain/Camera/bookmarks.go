@@ -0,0 +1,59 @@
+package camera
+
+import (
+    "github.com/go-gl/mathgl/mgl32"
+    "encoding/json"
+    "fmt"
+    "os"
+)
+
+// A saved camera pose. A bookmark file is a JSON list of them:
+//
+//    [
+//        {"name": "overview", "pos": [-20, 45, -20], "center": [80, 5, 80], "up": [0, 1, 0]},
+//        {"pos": [30, 18, 30], "center": [45, 6, 45], "up": [0, 1, 0]}
+//    ]
+type Bookmark struct {
+    Name    string      `json:"name,omitempty"`
+    Pos     mgl32.Vec3  `json:"pos"`
+    Center  mgl32.Vec3  `json:"center"`
+    Up      mgl32.Vec3  `json:"up"`
+}
+
+func (c *Camera) Bookmark(name string) Bookmark {
+    return Bookmark{name, c.Pos, c.Center, c.Up}
+}
+
+func (c *Camera) GoTo(b Bookmark) {
+    c.SetLookAt(b.Pos, b.Center, b.Up)
+}
+
+// A missing file is no error, there are just no bookmarks yet.
+func LoadBookmarks(fileName string) ([]Bookmark, error) {
+    data, err := os.ReadFile(fileName)
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    var bookmarks []Bookmark
+    if err := json.Unmarshal(data, &bookmarks); err != nil {
+        return nil, fmt.Errorf("%v: %v", fileName, err)
+    }
+    for i,b := range bookmarks {
+        if b.Up.Len() < 1e-6 || b.Center.Sub(b.Pos).Len() < 1e-6 {
+            return nil, fmt.Errorf("%v: bookmark %v has no up or view direction", fileName, i+1)
+        }
+    }
+    return bookmarks, nil
+}
+
+func SaveBookmarks(fileName string, bookmarks []Bookmark) error {
+    data, err := json.MarshalIndent(bookmarks, "", "    ")
+    if err != nil {
+        return err
+    }
+    return os.WriteFile(fileName, append(data, '\n'), 0644)
+}
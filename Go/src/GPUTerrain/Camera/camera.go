@@ -3,6 +3,7 @@ package camera
 import (
     "github.com/go-gl/mathgl/mgl32"
     "github.com/go-gl/glfw/v3.2/glfw"
+    "math"
 )
 
 const (
@@ -10,24 +11,168 @@ const (
     ROTATE_SCALE   = 0.5
     UPDOWN_SCALE   = 0.15
     DISTANCE_SCALE = 0.1
+    // Degrees per pixel of mouse movement in fly mode.
+    LOOK_SCALE     = 0.15
+    // World units per second in fly mode. Scrolling changes it by SPEED_SCALE.
+    FLY_SPEED      = 10.0
+    SPEED_SCALE    = 1.25
+    // Shift flies faster, control slower.
+    FAST_FACTOR    = 4.0
+    SLOW_FACTOR    = 0.25
+    // Looking straight up or down flips the view with LookAt.
+    MAX_PITCH      = 89.0
 )
 
-var g_cameraPos mgl32.Vec3 = mgl32.Vec3{0,8,15}
-var g_center    mgl32.Vec3 = mgl32.Vec3{0,0,0}
-var g_up        mgl32.Vec3 = mgl32.Vec3{0,1,0}
+type CameraMode int
 
-var g_angle float32 = 0.0
-var g_cursorPos mgl32.Vec2
-var g_leftButtonDown bool  = false
+const (
+    // Drag rotates around the center, scroll changes the distance.
+    OrbitMode CameraMode = iota
+    // WASD moves, Q and E move down and up, the mouse looks around.
+    FlyMode
+)
 
+func (m CameraMode) String() string {
+    if m == FlyMode {
+        return "fly"
+    }
+    return "orbit"
+}
 
+// A perspective camera with its own input state, so every view (the window, a split screen half,
+// an offscreen render) can have its own. The window events are passed on with the Update methods.
+type Camera struct {
+    Pos             mgl32.Vec3
+    Center          mgl32.Vec3
+    Up              mgl32.Vec3
+    // Vertical field of view in radians.
+    Fovy            float32
+    Aspect          float32
+    Near, Far       float32
+    // World units per second in fly mode.
+    FlySpeed        float32
+
+    mode            CameraMode
+    // View direction in fly mode, in degrees. Yaw 0 looks along -z.
+    yaw, pitch      float32
+    // Distance of the orbit camera to its center, when fly mode started. Orbit mode comes back with it.
+    orbitDistance   float32
+    cursorPos       mgl32.Vec2
+    leftButtonDown  bool
+    // Keys, that are held down right now. Movement happens every frame in Update, not on key repeat.
+    keysDown        map[glfw.Key]bool
+}
+
+// An orbit camera. fovy is in radians.
+func NewCamera(pos, center, up mgl32.Vec3, fovy, aspect, near, far float32) *Camera {
+    return &Camera {
+        Pos:            pos,
+        Center:         center,
+        Up:             up,
+        Fovy:           fovy,
+        Aspect:         aspect,
+        Near:           near,
+        Far:            far,
+        FlySpeed:       FLY_SPEED,
+        mode:           OrbitMode,
+        orbitDistance:  1.0,
+        keysDown:       map[glfw.Key]bool{},
+    }
+}
 
 func toRad(a float32) float32 {
     return a*PI/180.
 }
 
+func toDeg(a float32) float32 {
+    return a*180./PI
+}
+
+func (c *Camera) ViewMatrix() mgl32.Mat4 {
+    return mgl32.LookAtV(c.Pos, c.Center, c.Up)
+}
+
+func (c *Camera) ProjectionMatrix() mgl32.Mat4 {
+    return mgl32.Perspective(c.Fovy, c.Aspect, c.Near, c.Far)
+}
+
+func (c *Camera) ViewProjectionMatrix() mgl32.Mat4 {
+    return c.ProjectionMatrix().Mul4(c.ViewMatrix())
+}
+
+// Normalized direction from the position to the center.
+func (c *Camera) Direction() mgl32.Vec3 {
+    return c.Center.Sub(c.Pos).Normalize()
+}
+
+func (c *Camera) Mode() CameraMode {
+    return c.mode
+}
+
+// Fly mode starts looking where the orbit camera looked. Orbit mode then rotates around
+// the point in front of the camera, with the distance the orbit camera had before.
+func (c *Camera) SetMode(mode CameraMode) {
+    if mode == c.mode {
+        return
+    }
+    switch mode {
+        case FlyMode:
+            if c.orbitDistance = c.Center.Sub(c.Pos).Len(); c.orbitDistance < 1e-3 {
+                c.orbitDistance = 1.0
+            }
+            c.lookAtCenter()
+        case OrbitMode:
+            c.Center = c.Pos.Add(c.flyDirection().Mul(c.orbitDistance))
+    }
+    c.mode = mode
+    c.keysDown = map[glfw.Key]bool{}
+}
+
+// For fixed views, like the scenes of the regression test.
+func (c *Camera) SetLookAt(pos, center, up mgl32.Vec3) {
+    c.Pos    = pos
+    c.Center = center
+    c.Up     = up
+    if c.mode == FlyMode {
+        c.lookAtCenter()
+    }
+}
+
+// Yaw and pitch of the fly camera towards the center. The center then moves to one unit in front of the camera.
+func (c *Camera) lookAtCenter() {
+    dir := c.Center.Sub(c.Pos)
+    if dir.Len() < 1e-6 {
+        dir = mgl32.Vec3{0,0,-1}
+    }
+    dir = dir.Normalize()
+    c.yaw   = toDeg(float32(math.Atan2(float64(dir.X()), float64(-dir.Z()))))
+    c.pitch = toDeg(float32(math.Asin(float64(mgl32.Clamp(dir.Y(), -1, 1)))))
+    c.pitch = mgl32.Clamp(c.pitch, -MAX_PITCH, MAX_PITCH)
+    c.Center = c.Pos.Add(c.flyDirection())
+}
+
+func (c *Camera) flyDirection() mgl32.Vec3 {
+    yaw, pitch := float64(toRad(c.yaw)), float64(toRad(c.pitch))
+    return mgl32.Vec3 {
+        float32(math.Sin(yaw) * math.Cos(pitch)),
+        float32(math.Sin(pitch)),
+        float32(-math.Cos(yaw) * math.Cos(pitch)),
+    }
+}
+
+// Orbit mode scales the distance to the center, fly mode the speed.
 // We ignore horizontal scrolling.
-func UpdateMouseScroll(xpos, ypos float64) {
+func (c *Camera) UpdateMouseScroll(xpos, ypos float64) {
+    if c.mode == FlyMode {
+        switch {
+            case ypos > 0:
+                c.FlySpeed *= SPEED_SCALE
+            case ypos < 0:
+                c.FlySpeed /= SPEED_SCALE
+        }
+        return
+    }
+
     var scale float32 = 1.0
     switch {
         case ypos > 0:
@@ -36,29 +181,87 @@ func UpdateMouseScroll(xpos, ypos float64) {
             scale += DISTANCE_SCALE
     }
 
-    g_cameraPos = g_cameraPos.Mul(scale)
+    c.Pos = c.Center.Add(c.Pos.Sub(c.Center).Mul(scale))
 }
 
 // We only work with the left mouse button and ignore modifiers for now!
-func UpdateMouseButton(button glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
-    g_leftButtonDown = button == glfw.MouseButtonLeft && action == glfw.Press
+func (c *Camera) UpdateMouseButton(button glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
+    c.leftButtonDown = button == glfw.MouseButtonLeft && action == glfw.Press
 }
 
-func UpdateCursorPos(xpos, ypos float64) {
+// In fly mode the cursor is expected to be disabled (captured), so every movement looks around.
+func (c *Camera) UpdateCursorPos(xpos, ypos float64) {
     new  := mgl32.Vec2{float32(xpos), float32(ypos)}
-    diff := mgl32.Vec2{float32(xpos), float32(ypos)}.Sub(g_cursorPos)
-    g_cursorPos = new
+    diff := mgl32.Vec2{float32(xpos), float32(ypos)}.Sub(c.cursorPos)
+    c.cursorPos = new
+
+    if c.mode == FlyMode {
+        c.yaw   = float32(math.Mod(float64(c.yaw + diff.X()*LOOK_SCALE), 360.0))
+        c.pitch = mgl32.Clamp(c.pitch - diff.Y()*LOOK_SCALE, -MAX_PITCH, MAX_PITCH)
+        c.Center = c.Pos.Add(c.flyDirection())
+        return
+    }
 
-    if g_leftButtonDown {
+    if c.leftButtonDown {
 
-        r := g_cameraPos.Len()
+        offset := c.Pos.Sub(c.Center)
+        r := offset.Len()
         rotateY := mgl32.Rotate3DY(toRad(-diff.X())*ROTATE_SCALE)
-        g_cameraPos = rotateY.Mul3x1(g_cameraPos)
-        g_cameraPos = g_cameraPos.Add(mgl32.Vec3{0,diff.Y()*UPDOWN_SCALE,0}).Normalize().Mul(r)
+        offset = rotateY.Mul3x1(offset)
+        offset = offset.Add(mgl32.Vec3{0,diff.Y()*UPDOWN_SCALE,0}).Normalize().Mul(r)
+        c.Pos = c.Center.Add(offset)
 
     }
 }
 
-func GetCameraLookAt() (mgl32.Vec3, mgl32.Vec3, mgl32.Vec3) {
-    return g_cameraPos, g_center, g_up
+// Remembers the movement keys. Other keys are ignored, so all key events can be passed on.
+func (c *Camera) UpdateKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+    switch action {
+        case glfw.Press:
+            c.keysDown[key] = true
+        case glfw.Release:
+            delete(c.keysDown, key)
+    }
+}
+
+// Moves the fly camera with the held keys. dt is the time since the last frame in seconds,
+// so the speed does not depend on the frame rate.
+func (c *Camera) Update(dt float32) {
+    if c.mode != FlyMode {
+        return
+    }
+
+    forward := c.flyDirection()
+    right := forward.Cross(c.Up).Normalize()
+    var move mgl32.Vec3
+    axes := []struct {
+        key glfw.Key
+        dir mgl32.Vec3
+    }{
+        {glfw.KeyW, forward},
+        {glfw.KeyS, forward.Mul(-1)},
+        {glfw.KeyD, right},
+        {glfw.KeyA, right.Mul(-1)},
+        {glfw.KeyE, c.Up},
+        {glfw.KeyQ, c.Up.Mul(-1)},
+    }
+    for _,axis := range axes {
+        if c.keysDown[axis.key] {
+            move = move.Add(axis.dir)
+        }
+    }
+    if move.Len() < 1e-6 {
+        return
+    }
+
+    speed := c.FlySpeed
+    if c.keysDown[glfw.KeyLeftShift] || c.keysDown[glfw.KeyRightShift] {
+        speed *= FAST_FACTOR
+    }
+    if c.keysDown[glfw.KeyLeftControl] || c.keysDown[glfw.KeyRightControl] {
+        speed *= SLOW_FACTOR
+    }
+
+    c.Pos = c.Pos.Add(move.Normalize().Mul(speed*dt))
+    c.Center = c.Pos.Add(forward)
 }
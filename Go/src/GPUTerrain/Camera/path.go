@@ -0,0 +1,81 @@
+package camera
+
+import (
+    "github.com/go-gl/mathgl/mgl32"
+    "fmt"
+    "math"
+)
+
+// A camera flight through bookmarks. Position, center and up each follow a uniform Catmull-Rom spline,
+// which passes through every bookmark. The first and last bookmark are repeated as outer control points.
+type CameraPath struct {
+    Bookmarks       []Bookmark
+    // Seconds from one bookmark to the next.
+    SegmentDuration float32
+}
+
+func NewCameraPath(bookmarks []Bookmark, segmentDuration float32) (*CameraPath, error) {
+    if len(bookmarks) < 2 {
+        return nil, fmt.Errorf("a camera path needs at least 2 bookmarks, not %v", len(bookmarks))
+    }
+    if segmentDuration <= 0 {
+        return nil, fmt.Errorf("segment duration %v has to be positive", segmentDuration)
+    }
+    return &CameraPath{bookmarks, segmentDuration}, nil
+}
+
+func (p *CameraPath) Duration() float32 {
+    return float32(len(p.Bookmarks)-1) * p.SegmentDuration
+}
+
+// Number of frames with a fixed time step of 1/fps, including the first and the last bookmark.
+func (p *CameraPath) FrameCount(fps float32) int {
+    return int(math.Floor(float64(p.Duration()*fps))) + 1
+}
+
+func catmullRom(p0, p1, p2, p3 mgl32.Vec3, s float32) mgl32.Vec3 {
+    s2 := s*s
+    s3 := s2*s
+    return p0.Mul(-s3 + 2*s2 - s).
+        Add(p1.Mul(3*s3 - 5*s2 + 2)).
+        Add(p2.Mul(-3*s3 + 4*s2 + s)).
+        Add(p3.Mul(s3 - s2)).
+        Mul(0.5)
+}
+
+// The pose t seconds after the start. t is clamped to the path.
+func (p *CameraPath) At(t float32) Bookmark {
+    t = mgl32.Clamp(t, 0, p.Duration())
+    segment := int(t / p.SegmentDuration)
+    if segment >= len(p.Bookmarks)-1 {
+        segment = len(p.Bookmarks)-2
+    }
+    s := t/p.SegmentDuration - float32(segment)
+
+    point := func(i int) Bookmark {
+        if i < 0 {
+            i = 0
+        }
+        if i >= len(p.Bookmarks) {
+            i = len(p.Bookmarks)-1
+        }
+        return p.Bookmarks[i]
+    }
+    b0, b1, b2, b3 := point(segment-1), point(segment), point(segment+1), point(segment+2)
+
+    // Opposite up vectors of two bookmarks cancel out somewhere in between. Normalizing
+    // would give NaN there, so the up vector of the last bookmark is kept instead.
+    up := catmullRom(b0.Up, b1.Up, b2.Up, b3.Up, s)
+    if up.Len() < 1e-4 {
+        up = b1.Up
+        if up.Len() < 1e-4 {
+            up = mgl32.Vec3{0, 1, 0}
+        }
+    }
+
+    return Bookmark {
+        Pos:    catmullRom(b0.Pos, b1.Pos, b2.Pos, b3.Pos, s),
+        Center: catmullRom(b0.Center, b1.Center, b2.Center, b3.Center, s),
+        Up:     up.Normalize(),
+    }
+}
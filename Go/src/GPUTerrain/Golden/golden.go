@@ -0,0 +1,133 @@
+package golden
+
+import (
+    "fmt"
+    "image"
+    "image/color"
+    "math"
+)
+
+// When two images are considered equal.
+// Different drivers (or llvmpipe versions) rasterize and round slightly differently, so exact equality is too strict.
+type Tolerance struct {
+    // Largest colour difference (CIE76 delta E) of two pixels, that are still equal. About 2.3 is just noticeable.
+    MaxDeltaE           float64
+    // A pixel also matches any golden pixel up to this many pixels away. Lines and points move by a pixel easily.
+    Radius              int
+    // Fraction of all pixels, that may still be different.
+    MaxDifferentPixels  float64
+}
+
+var DefaultTolerance = Tolerance {
+    MaxDeltaE:          5.0,
+    Radius:             1,
+    MaxDifferentPixels: 0.002,
+}
+
+type Result struct {
+    DifferentPixels int
+    TotalPixels     int
+    // Of the worst pixel.
+    MaxDeltaE       float64
+    Passed          bool
+    // The actual image in grey, different pixels in red. The more different, the brighter.
+    Diff            *image.RGBA
+}
+
+func (r Result) String() string {
+    return fmt.Sprintf("%v of %v pixels differ (%.3f%%), max delta E %.1f", r.DifferentPixels, r.TotalPixels,
+                       100.0*float64(r.DifferentPixels)/float64(r.TotalPixels), r.MaxDeltaE)
+}
+
+type lab struct {
+    L, A, B float64
+}
+
+func linear(c uint32) float64 {
+    v := float64(c) / 0xffff
+    if v <= 0.04045 {
+        return v / 12.92
+    }
+    return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// sRGB -> XYZ (D65) -> CIE L*a*b*
+func toLab(c color.Color) lab {
+    r, g, b, _ := c.RGBA()
+    lr, lg, lb := linear(r), linear(g), linear(b)
+
+    x := (0.4124*lr + 0.3576*lg + 0.1805*lb) / 0.95047
+    y := (0.2126*lr + 0.7152*lg + 0.0722*lb)
+    z := (0.0193*lr + 0.1192*lg + 0.9505*lb) / 1.08883
+
+    f := func(t float64) float64 {
+        if t > 0.008856 {
+            return math.Cbrt(t)
+        }
+        return 7.787*t + 16.0/116.0
+    }
+    fx, fy, fz := f(x), f(y), f(z)
+    return lab{116.0*fy - 16.0, 500.0*(fx-fy), 200.0*(fy-fz)}
+}
+
+func deltaE(a, b lab) float64 {
+    return math.Sqrt((a.L-b.L)*(a.L-b.L) + (a.A-b.A)*(a.A-b.A) + (a.B-b.B)*(a.B-b.B))
+}
+
+func toLabImage(img image.Image) []lab {
+    bounds := img.Bounds()
+    pixels := make([]lab, bounds.Dx()*bounds.Dy())
+    for y := 0; y < bounds.Dy(); y++ {
+        for x := 0; x < bounds.Dx(); x++ {
+            pixels[y*bounds.Dx()+x] = toLab(img.At(bounds.Min.X+x, bounds.Min.Y+y))
+        }
+    }
+    return pixels
+}
+
+// Compares the actual render against the golden image. Both must have the same size.
+func Compare(golden, actual image.Image, tolerance Tolerance) (Result, error) {
+    width, height := actual.Bounds().Dx(), actual.Bounds().Dy()
+    if golden.Bounds().Dx() != width || golden.Bounds().Dy() != height {
+        return Result{}, fmt.Errorf("golden image is %vx%v, the render %vx%v", golden.Bounds().Dx(), golden.Bounds().Dy(), width, height)
+    }
+
+    goldenLab := toLabImage(golden)
+    actualLab := toLabImage(actual)
+
+    result := Result {
+        TotalPixels: width*height,
+        Diff:        image.NewRGBA(image.Rect(0, 0, width, height)),
+    }
+
+    for y := 0; y < height; y++ {
+        for x := 0; x < width; x++ {
+            a := actualLab[y*width+x]
+
+            // The closest pixel in the neighbourhood counts.
+            best := math.MaxFloat64
+            for dy := -tolerance.Radius; dy <= tolerance.Radius; dy++ {
+                for dx := -tolerance.Radius; dx <= tolerance.Radius; dx++ {
+                    gx, gy := x+dx, y+dy
+                    if gx < 0 || gy < 0 || gx >= width || gy >= height {
+                        continue
+                    }
+                    best = math.Min(best, deltaE(a, goldenLab[gy*width+gx]))
+                }
+            }
+
+            result.MaxDeltaE = math.Max(result.MaxDeltaE, best)
+            if best > tolerance.MaxDeltaE {
+                result.DifferentPixels += 1
+                red := uint8(math.Min(255, 128 + best*2))
+                result.Diff.SetRGBA(x, y, color.RGBA{red, 0, 0, 255})
+            } else {
+                grey := uint8(a.L / 100.0 * 255.0 * 0.4)
+                result.Diff.SetRGBA(x, y, color.RGBA{grey, grey, grey, 255})
+            }
+        }
+    }
+
+    result.Passed = float64(result.DifferentPixels) <= tolerance.MaxDifferentPixels*float64(result.TotalPixels)
+    return result, nil
+}
@@ -0,0 +1,113 @@
+package golden
+
+import (
+    "image"
+    "image/color"
+    "math/rand"
+    "testing"
+)
+
+// Random pixels, so every shift changes almost every pixel.
+func noiseImage(width, height int) *image.RGBA {
+    random := rand.New(rand.NewSource(1))
+    img := image.NewRGBA(image.Rect(0, 0, width, height))
+    for y := 0; y < height; y++ {
+        for x := 0; x < width; x++ {
+            img.SetRGBA(x, y, color.RGBA{uint8(random.Intn(256)), uint8(random.Intn(256)), uint8(random.Intn(256)), 255})
+        }
+    }
+    return img
+}
+
+// Moves the image one pixel to the right. The first column is repeated.
+func shiftRight(img *image.RGBA) *image.RGBA {
+    bounds := img.Bounds()
+    shifted := image.NewRGBA(bounds)
+    for y := 0; y < bounds.Dy(); y++ {
+        for x := 0; x < bounds.Dx(); x++ {
+            from := x-1
+            if from < 0 {
+                from = 0
+            }
+            shifted.Set(x, y, img.At(from, y))
+        }
+    }
+    return shifted
+}
+
+func compare(t *testing.T, golden, actual image.Image, tolerance Tolerance) Result {
+    t.Helper()
+    result, err := Compare(golden, actual, tolerance)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return result
+}
+
+func TestIdentical(t *testing.T) {
+    img := noiseImage(32, 24)
+    result := compare(t, img, img, Tolerance{})
+    if !result.Passed || result.DifferentPixels != 0 || result.MaxDeltaE != 0 {
+        t.Errorf("identical images: %v, passed %v", result, result.Passed)
+    }
+}
+
+func TestShiftWithinRadius(t *testing.T) {
+    golden := noiseImage(32, 24)
+    shifted := shiftRight(golden)
+
+    result := compare(t, golden, shifted, Tolerance{MaxDeltaE: 0.1, Radius: 1})
+    if !result.Passed || result.DifferentPixels != 0 {
+        t.Errorf("shift by one pixel with radius 1: %v, passed %v", result, result.Passed)
+    }
+
+    result = compare(t, golden, shifted, Tolerance{MaxDeltaE: 0.1, Radius: 0})
+    if result.Passed {
+        t.Errorf("shift by one pixel with radius 0 passed: %v", result)
+    }
+}
+
+func TestColourChange(t *testing.T) {
+    golden := noiseImage(32, 24)
+    changed := noiseImage(32, 24)
+    for x := 0; x < 32; x++ {
+        changed.SetRGBA(x, 10, color.RGBA{0, 255, 0, 255})
+    }
+
+    // One of 24 rows is more than the allowed fraction.
+    result := compare(t, golden, changed, DefaultTolerance)
+    if result.Passed {
+        t.Errorf("changed row passed: %v", result)
+    }
+    if result.DifferentPixels == 0 || result.DifferentPixels > 32 {
+        t.Errorf("%v different pixels, want some of the 32 changed ones", result.DifferentPixels)
+    }
+    if result.MaxDeltaE <= DefaultTolerance.MaxDeltaE {
+        t.Errorf("max delta E %v is not above the tolerance", result.MaxDeltaE)
+    }
+
+    // Different pixels are red in the diff, the others grey.
+    for x := 0; x < 32; x++ {
+        for y := 0; y < 24; y++ {
+            c := result.Diff.RGBAAt(x, y)
+            if y != 10 && (c.R != c.G || c.G != c.B) {
+                t.Fatalf("unchanged pixel %v,%v is marked with %v", x, y, c)
+            }
+        }
+    }
+    marked := 0
+    for x := 0; x < 32; x++ {
+        if c := result.Diff.RGBAAt(x, 10); c.R >= 128 && c.G == 0 && c.B == 0 {
+            marked += 1
+        }
+    }
+    if marked != result.DifferentPixels {
+        t.Errorf("%v pixels are marked red, but %v differ", marked, result.DifferentPixels)
+    }
+}
+
+func TestSizeMismatch(t *testing.T) {
+    if _, err := Compare(noiseImage(32, 24), noiseImage(24, 32), DefaultTolerance); err == nil {
+        t.Error("images of different sizes compared without error")
+    }
+}
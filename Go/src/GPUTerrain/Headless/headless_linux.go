@@ -0,0 +1,85 @@
+// +build linux
+
+package headless
+
+/*
+#cgo LDFLAGS: -lEGL
+#include <EGL/egl.h>
+#include <EGL/eglext.h>
+
+static EGLDisplay g_display = EGL_NO_DISPLAY;
+static EGLContext g_context = EGL_NO_CONTEXT;
+
+// Surfaceless, so neither a display server nor a GPU is needed. Mesa falls back to llvmpipe.
+static int createContext(int major, int minor) {
+    PFNEGLGETPLATFORMDISPLAYEXTPROC getPlatformDisplay = (PFNEGLGETPLATFORMDISPLAYEXTPROC)eglGetProcAddress("eglGetPlatformDisplayEXT");
+    if (getPlatformDisplay == NULL) {
+        return 1;
+    }
+    g_display = getPlatformDisplay(EGL_PLATFORM_SURFACELESS_MESA, EGL_DEFAULT_DISPLAY, NULL);
+    if (g_display == EGL_NO_DISPLAY || !eglInitialize(g_display, NULL, NULL)) {
+        return 2;
+    }
+    if (!eglBindAPI(EGL_OPENGL_API)) {
+        return 3;
+    }
+    EGLint attributes[] = {
+        EGL_CONTEXT_MAJOR_VERSION, major,
+        EGL_CONTEXT_MINOR_VERSION, minor,
+        EGL_CONTEXT_OPENGL_PROFILE_MASK, EGL_CONTEXT_OPENGL_CORE_PROFILE_BIT,
+        EGL_NONE
+    };
+    g_context = eglCreateContext(g_display, EGL_NO_CONFIG_KHR, EGL_NO_CONTEXT, attributes);
+    if (g_context == EGL_NO_CONTEXT) {
+        return 4;
+    }
+    if (!eglMakeCurrent(g_display, EGL_NO_SURFACE, EGL_NO_SURFACE, g_context)) {
+        return 5;
+    }
+    return 0;
+}
+
+static void destroyContext() {
+    if (g_display == EGL_NO_DISPLAY) {
+        return;
+    }
+    eglMakeCurrent(g_display, EGL_NO_SURFACE, EGL_NO_SURFACE, EGL_NO_CONTEXT);
+    if (g_context != EGL_NO_CONTEXT) {
+        eglDestroyContext(g_display, g_context);
+    }
+    eglTerminate(g_display);
+    g_display = EGL_NO_DISPLAY;
+    g_context = EGL_NO_CONTEXT;
+}
+*/
+import "C"
+import (
+    "github.com/go-gl/gl/v4.5-core/gl"
+    "fmt"
+)
+
+var contextErrors = map[C.int]string {
+    1: "eglGetPlatformDisplayEXT is not available",
+    2: "no surfaceless EGL display",
+    3: "OpenGL is not supported by EGL",
+    4: "could not create the OpenGL context",
+    5: "could not make the OpenGL context current",
+}
+
+// Creates an OpenGL core context without any window or surface and makes it current.
+// There is no default framebuffer, so everything has to be rendered into an FBO.
+// Must be called from the locked main thread, like glfw.
+func InitHeadlessContext(major, minor int) error {
+    if r := C.createContext(C.int(major), C.int(minor)); r != 0 {
+        eglError := int(C.eglGetError())
+        C.destroyContext()
+        return fmt.Errorf("headless context: %v (EGL error 0x%x)", contextErrors[r], eglError)
+    }
+
+    // Initialize Glow
+    return gl.Init()
+}
+
+func TerminateHeadlessContext() {
+    C.destroyContext()
+}
@@ -0,0 +1,15 @@
+// +build !linux
+
+package headless
+
+import (
+    "errors"
+)
+
+// Headless rendering needs EGL from Mesa, which is only there on Linux.
+func InitHeadlessContext(major, minor int) error {
+    return errors.New("headless rendering is only supported on linux")
+}
+
+func TerminateHeadlessContext() {
+}
@@ -0,0 +1,348 @@
+package mesher
+
+// Derives the marching cubes tables from the topology of the cube instead of pasting them.
+//
+// The surface crosses every edge between a solid and an empty corner. On every face of the cube,
+// these crossings are connected to line segments, that cut off the corners of one state.
+// The segments of all faces form closed loops around the cube, which are then triangulated as fans.
+// Only faces with two diagonal solid and two diagonal empty corners are ambiguous. How they are
+// separated is chosen by an Ambiguity.
+
+import (
+    "fmt"
+    "sort"
+)
+
+type Topology struct {
+    // Position of every corner in the unit cube. Corner i is bit i of the case.
+    Corners [8][3]int
+    // The two corners of every edge.
+    Edges   [12][2]int
+}
+
+// The corner bit ordering of caseNumberFromVertices and the edges of getIntersectionFromEdge.
+var ShaderTopology = Topology{cornerOffsets, edgeCorners}
+
+// Checks, that the corners and edges actually describe a unit cube.
+func (t Topology) Validate() error {
+    for c1 := 0; c1 < 8; c1++ {
+        for k := 0; k < 3; k++ {
+            if t.Corners[c1][k] != 0 && t.Corners[c1][k] != 1 {
+                return fmt.Errorf("corner %v is not a corner of the unit cube", c1)
+            }
+        }
+        for c2 := c1+1; c2 < 8; c2++ {
+            if t.Corners[c1] == t.Corners[c2] {
+                return fmt.Errorf("corners %v and %v are at the same position", c1, c2)
+            }
+        }
+    }
+    for e,corners := range t.Edges {
+        differences := 0
+        for k := 0; k < 3; k++ {
+            if t.Corners[corners[0]][k] != t.Corners[corners[1]][k] {
+                differences += 1
+            }
+        }
+        if differences != 1 {
+            return fmt.Errorf("edge %v does not connect two neighbouring corners", e)
+        }
+        // edge returns the first one with these corners.
+        if first := t.edge(corners[0], corners[1]); first != e {
+            return fmt.Errorf("edges %v and %v connect the same corners", first, e)
+        }
+    }
+    return nil
+}
+
+// How an ambiguous face is separated.
+type Ambiguity int
+
+const (
+    // Always cuts off the solid corners. Neighbouring cubes agree on their common face, so there are no holes
+    // in the surface. This is, what the shipped tables do.
+    SeparateSolid Ambiguity = iota
+    // Always cuts off the empty corners. Also without holes, but solid diagonals are connected.
+    SeparateEmpty
+    // Cuts off the corners of the state, that fewer corners of the cube have (solid, if both have 4).
+    // A case and its complement get the same surface, like in the original paper. Neighbouring cubes
+    // may disagree on a face, which leaves holes.
+    SeparateMinority
+)
+
+// Every case has room for 5 triangles in edgeConnectList (15*case in the compute shader).
+const MaxTrianglesPerCase = 5
+
+// The corners of one face in order around it.
+type face [4]int
+
+func (t Topology) faces() []face {
+    var faces []face
+    for axis := 0; axis < 3; axis++ {
+        for value := 0; value <= 1; value++ {
+            var corners []int
+            for c,pos := range t.Corners {
+                if pos[axis] == value {
+                    corners = append(corners, c)
+                }
+            }
+            // Walk around the face along its edges.
+            f := face{corners[0]}
+            used := map[int]bool{corners[0]: true}
+            for i := 1; i < 4; i++ {
+                for _,c := range corners {
+                    if !used[c] && t.edge(f[i-1], c) >= 0 {
+                        f[i] = c
+                        used[c] = true
+                        break
+                    }
+                }
+            }
+            faces = append(faces, f)
+        }
+    }
+    return faces
+}
+
+// The edge between two corners or -1.
+func (t Topology) edge(c1, c2 int) int {
+    for e,corners := range t.Edges {
+        if (corners[0] == c1 && corners[1] == c2) || (corners[0] == c2 && corners[1] == c1) {
+            return e
+        }
+    }
+    return -1
+}
+
+func solid(cubeCase, corner int) bool {
+    return (cubeCase >> uint(corner)) & 1 == 1
+}
+
+// The closed loops of crossed edges of one case. Oriented counter-clockwise, seen from the empty side.
+func (t Topology) loops(cubeCase int, ambiguity Ambiguity) [][]int {
+    solidCount := 0
+    for c := 0; c < 8; c++ {
+        if solid(cubeCase, c) {
+            solidCount += 1
+        }
+    }
+    separateSolid := ambiguity == SeparateSolid || (ambiguity == SeparateMinority && solidCount <= 4)
+
+    // Every crossed edge is connected to exactly two others, one on each of its faces.
+    neighbours := make(map[int][]int)
+    connect := func(e1, e2 int) {
+        neighbours[e1] = append(neighbours[e1], e2)
+        neighbours[e2] = append(neighbours[e2], e1)
+    }
+    for _,f := range t.faces() {
+        var crossed []int
+        for i := 0; i < 4; i++ {
+            if solid(cubeCase, f[i]) != solid(cubeCase, f[(i+1)%4]) {
+                crossed = append(crossed, t.edge(f[i], f[(i+1)%4]))
+            }
+        }
+        switch len(crossed) {
+            case 2:
+                connect(crossed[0], crossed[1])
+            case 4:
+                // Cut off both corners of one state with their two adjacent edges.
+                for i := 0; i < 4; i++ {
+                    if solid(cubeCase, f[i]) == separateSolid {
+                        connect(t.edge(f[(i+3)%4], f[i]), t.edge(f[i], f[(i+1)%4]))
+                    }
+                }
+        }
+    }
+
+    var edges []int
+    for e,_ := range neighbours {
+        edges = append(edges, e)
+    }
+    sort.Ints(edges)
+
+    var loops [][]int
+    visited := make(map[int]bool)
+    for _,start := range edges {
+        if visited[start] {
+            continue
+        }
+        loop := []int{start}
+        visited[start] = true
+        for previous, current := -1, start; ; {
+            next := neighbours[current][0]
+            if next == previous || visited[next] {
+                next = neighbours[current][1]
+            }
+            if visited[next] {
+                break
+            }
+            loop = append(loop, next)
+            visited[next] = true
+            previous, current = current, next
+        }
+        loops = append(loops, t.orient(cubeCase, loop))
+    }
+    return loops
+}
+
+func (t Topology) midpoint(e int) [3]float64 {
+    var p [3]float64
+    for i := 0; i < 3; i++ {
+        p[i] = float64(t.Corners[t.Edges[e][0]][i] + t.Corners[t.Edges[e][1]][i]) / 2.0
+    }
+    return p
+}
+
+// Reverses the loop, if its normal (Newell's method) does not point from the solid to the empty corners.
+func (t Topology) orient(cubeCase int, loop []int) []int {
+    var normal, outside [3]float64
+    for i,e := range loop {
+        p, q := t.midpoint(e), t.midpoint(loop[(i+1)%len(loop)])
+        normal[0] += (p[1] - q[1]) * (p[2] + q[2])
+        normal[1] += (p[2] - q[2]) * (p[0] + q[0])
+        normal[2] += (p[0] - q[0]) * (p[1] + q[1])
+
+        from, to := t.Edges[e][0], t.Edges[e][1]
+        if !solid(cubeCase, from) {
+            from, to = to, from
+        }
+        for k := 0; k < 3; k++ {
+            outside[k] += float64(t.Corners[to][k] - t.Corners[from][k])
+        }
+    }
+    if normal[0]*outside[0] + normal[1]*outside[1] + normal[2]*outside[2] < 0 {
+        for i, j := 0, len(loop)-1; i < j; i, j = i+1, j-1 {
+            loop[i], loop[j] = loop[j], loop[i]
+        }
+    }
+    return loop
+}
+
+// Creates caseToNumPolys and edgeConnectList for the topology.
+// Returns an error, if a case needs more than MaxTrianglesPerCase triangles.
+func GenerateTables(topology Topology, ambiguity Ambiguity) (Tables, error) {
+    if err := topology.Validate(); err != nil {
+        return Tables{}, err
+    }
+    tables := Tables {
+        CaseToNumPolys:  make([]int32, 256),
+        EdgeConnectList: make([]int32, 256*MaxTrianglesPerCase*3),
+    }
+    for i,_ := range tables.EdgeConnectList {
+        tables.EdgeConnectList[i] = -1
+    }
+
+    for cubeCase := 0; cubeCase < 256; cubeCase++ {
+        var triangles [][3]int
+        for _,loop := range topology.loops(cubeCase, ambiguity) {
+            for i := 1; i+1 < len(loop); i++ {
+                triangles = append(triangles, [3]int{loop[0], loop[i], loop[i+1]})
+            }
+        }
+        if len(triangles) > MaxTrianglesPerCase {
+            return tables, fmt.Errorf("case %v needs %v triangles, only %v fit into edgeConnectList", cubeCase, len(triangles), MaxTrianglesPerCase)
+        }
+        tables.CaseToNumPolys[cubeCase] = int32(len(triangles))
+        for i,triangle := range triangles {
+            for v,e := range triangle {
+                tables.EdgeConnectList[15*cubeCase + 3*i + v] = int32(e)
+            }
+        }
+    }
+    return tables, nil
+}
+
+// The loops around the triangles of one case, each starting with its smallest edge.
+// Edges inside of a loop are used by two triangles in opposite directions. The remaining ones form the loop.
+func (tables Tables) caseLoops(cubeCase int) ([][]int, error) {
+    next := make(map[int]int)
+    count := int(tables.CaseToNumPolys[cubeCase])
+    inner := make(map[[2]int]bool)
+    for t := 0; t < count; t++ {
+        triangle := tables.EdgeConnectList[15*cubeCase+3*t : 15*cubeCase+3*t+3]
+        for v := 0; v < 3; v++ {
+            from, to := int(triangle[v]), int(triangle[(v+1)%3])
+            if inner[[2]int{to, from}] {
+                delete(inner, [2]int{to, from})
+                continue
+            }
+            inner[[2]int{from, to}] = true
+        }
+    }
+    for directed,_ := range inner {
+        if _, ok := next[directed[0]]; ok {
+            return nil, fmt.Errorf("case %v: edge %v is part of more than one loop or inconsistently oriented", cubeCase, directed[0])
+        }
+        next[directed[0]] = directed[1]
+    }
+
+    var starts []int
+    for e,_ := range next {
+        starts = append(starts, e)
+    }
+    sort.Ints(starts)
+
+    var loops [][]int
+    visited := make(map[int]bool)
+    for _,start := range starts {
+        if visited[start] {
+            continue
+        }
+        var loop []int
+        for e := start; !visited[e]; e = next[e] {
+            loop = append(loop, e)
+            visited[e] = true
+        }
+        if next[loop[len(loop)-1]] != start {
+            return nil, fmt.Errorf("case %v: the triangles do not form closed loops", cubeCase)
+        }
+        loops = append(loops, loop)
+    }
+    return loops, nil
+}
+
+func rotateToMin(loop []int) []int {
+    m := 0
+    for i,e := range loop {
+        if e < loop[m] {
+            m = i
+        }
+    }
+    return append(append([]int{}, loop[m:]...), loop[:m]...)
+}
+
+func loopsString(loops [][]int) string {
+    var keys []string
+    for _,loop := range loops {
+        keys = append(keys, fmt.Sprint(rotateToMin(loop)))
+    }
+    sort.Strings(keys)
+    return fmt.Sprint(keys)
+}
+
+// Compares two sets of tables case by case. The triangles of a case do not have to be the same,
+// as long as they cover the same loops with the same orientation and count.
+// Returns one line per differing case.
+func CompareTables(expected, actual Tables) []string {
+    var differences []string
+    for cubeCase := 0; cubeCase < 256; cubeCase++ {
+        if expected.CaseToNumPolys[cubeCase] != actual.CaseToNumPolys[cubeCase] {
+            differences = append(differences, fmt.Sprintf("case %v: %v triangles instead of %v", cubeCase, actual.CaseToNumPolys[cubeCase], expected.CaseToNumPolys[cubeCase]))
+            continue
+        }
+        expectedLoops, err := expected.caseLoops(cubeCase)
+        if err != nil {
+            differences = append(differences, "expected "+err.Error())
+            continue
+        }
+        actualLoops, err := actual.caseLoops(cubeCase)
+        if err != nil {
+            differences = append(differences, err.Error())
+            continue
+        }
+        if e, a := loopsString(expectedLoops), loopsString(actualLoops); e != a {
+            differences = append(differences, fmt.Sprintf("case %v: loops %v instead of %v", cubeCase, a, e))
+        }
+    }
+    return differences
+}
@@ -0,0 +1,85 @@
+package mesher
+
+import (
+    "testing"
+)
+
+func copyTables(tables Tables) Tables {
+    return Tables {
+        CaseToNumPolys:  append([]int32{}, tables.CaseToNumPolys...),
+        EdgeConnectList: append([]int32{}, tables.EdgeConnectList...),
+    }
+}
+
+func TestShaderTopology(t *testing.T) {
+    if err := ShaderTopology.Validate(); err != nil {
+        t.Fatal(err)
+    }
+}
+
+// The shipped tables have to match the ones generated from the cube topology.
+func TestShippedTables(t *testing.T) {
+    generated, err := GenerateTables(ShaderTopology, SeparateSolid)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _,difference := range CompareTables(generated, ShippedTables) {
+        t.Error(difference)
+    }
+}
+
+// Every difference in edgeConnectList has to be found.
+func TestChangedTables(t *testing.T) {
+    generated, err := GenerateTables(ShaderTopology, SeparateSolid)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // Case 1 is the triangle 0,8,3 around corner 0. Edge 9 belongs to corner 1.
+    changed := copyTables(ShippedTables)
+    changed.EdgeConnectList[15*1 + 1] = 9
+    if differences := CompareTables(generated, changed); len(differences) != 1 {
+        t.Errorf("one changed edge gives %v differences, want 1: %v", len(differences), differences)
+    }
+
+    // Reversed triangle, so the surface faces the other way.
+    changed = copyTables(ShippedTables)
+    changed.EdgeConnectList[15*1 + 1], changed.EdgeConnectList[15*1 + 2] = changed.EdgeConnectList[15*1 + 2], changed.EdgeConnectList[15*1 + 1]
+    if differences := CompareTables(generated, changed); len(differences) != 1 {
+        t.Errorf("one flipped triangle gives %v differences, want 1: %v", len(differences), differences)
+    }
+
+    changed = copyTables(ShippedTables)
+    changed.CaseToNumPolys[3] = 1
+    if differences := CompareTables(generated, changed); len(differences) != 1 {
+        t.Errorf("one changed triangle count gives %v differences, want 1: %v", len(differences), differences)
+    }
+}
+
+// A case and its complement cut the same edges.
+func TestGeneratedComplements(t *testing.T) {
+    for _,ambiguity := range []Ambiguity{SeparateSolid, SeparateEmpty, SeparateMinority} {
+        tables, err := GenerateTables(ShaderTopology, ambiguity)
+        if err != nil {
+            t.Fatal(err)
+        }
+        for cubeCase := 0; cubeCase < 256; cubeCase++ {
+            edges := func(c int) map[int32]bool {
+                used := map[int32]bool{}
+                for i := 0; i < 3*int(tables.CaseToNumPolys[c]); i++ {
+                    used[tables.EdgeConnectList[15*c+i]] = true
+                }
+                return used
+            }
+            a, b := edges(cubeCase), edges(255-cubeCase)
+            if len(a) != len(b) {
+                t.Fatalf("ambiguity %v: case %v uses %v edges, its complement %v", ambiguity, cubeCase, len(a), len(b))
+            }
+            for e,_ := range a {
+                if !b[e] {
+                    t.Fatalf("ambiguity %v: case %v uses edge %v, its complement does not", ambiguity, cubeCase, e)
+                }
+            }
+        }
+    }
+}
@@ -0,0 +1,466 @@
+package mesher
+
+// CPU reference implementation of marchingCubes.comp.
+// It produces the same buffers as the compute shader (cases, layout sizes after the prefix sum and triangles),
+// in the same order, so the GPU result can be compared against it cube by cube.
+// It is written for clarity and not for speed. The densities are only sampled once per corner.
+
+import (
+    "github.com/go-gl/mathgl/mgl32"
+    "fmt"
+    "math"
+)
+
+const (
+    // Corners with a density closer than this to the iso-level might end up on the other side on the GPU,
+    // because its sin/exp/sqrt are less precise. A different case for those cubes is expected.
+    AmbiguityThreshold = 1e-3
+)
+
+// The density function, i.e. a CPU version of getDensityAtPosition.
+type Density func(pos mgl32.Vec3) float32
+
+// Optional material of a density field, like getMaterialAtPosition in the compute shader.
+type Material struct {
+    // Blended over the surface by its alpha.
+    Color   mgl32.Vec4
+    // Texture layer (see materials.glsl). NoMaterial for the rules of the renderer.
+    ID      float32
+}
+
+const NoMaterial = -1
+
+// The material at a position in world units.
+type Materials func(pos mgl32.Vec3) Material
+
+// Corner i of a cube is bit i of its case. Same order as createCase in the compute shader.
+var cornerOffsets = [8][3]int {
+    {0,0,0}, {0,1,0}, {1,1,0}, {1,0,0},
+    {0,0,1}, {0,1,1}, {1,1,1}, {1,0,1},
+}
+
+// The two corners of every edge, interpolated from the first to the second.
+// Same as getIntersectionFromEdge in the compute shader.
+var edgeCorners = [12][2]int {
+    {0,1}, {1,2}, {3,2}, {0,3},
+    {4,5}, {5,6}, {7,6}, {4,7},
+    {0,4}, {1,5}, {2,6}, {3,7},
+}
+
+type Tables struct {
+    CaseToNumPolys  []int32
+    EdgeConnectList []int32
+}
+
+// The tables, that are uploaded to the GPU.
+var ShippedTables = Tables{CaseToNumPolys[:], EdgeConnectList[:]}
+
+// The cubes, that are extracted. Same as the units and iso-surfaces of the compute shader.
+type Grid struct {
+    // Cubes per unit (CHUNK_SIZE in the shader).
+    ChunkSize   [3]int
+    // Position of the first cube of every unit. In cubes, not world units.
+    UnitOffsets []mgl32.Vec3
+    IsoLevels   []float32
+    // Edge length of one cube in world units (cubeSize in the shader).
+    CubeSize    float32
+}
+
+func (g Grid) CubesPerUnit() int {
+    return g.ChunkSize[0]*g.ChunkSize[1]*g.ChunkSize[2]
+}
+
+// Cubes of all units for one iso-surface (totalCubeCount in the shader).
+func (g Grid) TotalCubeCount() int {
+    return len(g.UnitOffsets)*g.CubesPerUnit()
+}
+
+// Index into the cases and layout buffers. Same as linearIndex in the compute shader.
+func (g Grid) CubeIndex(x, y, z, unit, surface int) int {
+    return g.ChunkSize[0]*g.ChunkSize[1]*z + g.ChunkSize[0]*y + x + unit*g.CubesPerUnit() + surface*g.TotalCubeCount()
+}
+
+// The density at every corner of every unit.
+type Field struct {
+    Grid        Grid
+    densities   [][]float32
+}
+
+func Sample(grid Grid, density Density) *Field {
+    field := &Field{Grid: grid, densities: make([][]float32, len(grid.UnitOffsets))}
+    sx, sy, sz := grid.ChunkSize[0]+1, grid.ChunkSize[1]+1, grid.ChunkSize[2]+1
+    for u,offset := range grid.UnitOffsets {
+        field.densities[u] = make([]float32, sx*sy*sz)
+        for z := 0; z < sz; z++ {
+            for y := 0; y < sy; y++ {
+                for x := 0; x < sx; x++ {
+                    field.densities[u][sx*sy*z + sx*y + x] = density(mgl32.Vec3{float32(x), float32(y), float32(z)}.Add(offset).Mul(grid.CubeSize))
+                }
+            }
+        }
+    }
+    return field
+}
+
+func (f *Field) Density(unit, x, y, z int) float32 {
+    sx, sy := f.Grid.ChunkSize[0]+1, f.Grid.ChunkSize[1]+1
+    return f.densities[unit][sx*sy*z + sx*y + x]
+}
+
+func (f *Field) cornerDensity(unit, x, y, z, corner int) float32 {
+    o := cornerOffsets[corner]
+    return f.Density(unit, x+o[0], y+o[1], z+o[2])
+}
+
+// If any corner of the cube is so close to the iso-level, that the GPU might classify it differently.
+func (f *Field) Ambiguous(unit, x, y, z, surface int) bool {
+    isoLevel := f.Grid.IsoLevels[surface]
+    for corner := 0; corner < 8; corner++ {
+        if abs(f.cornerDensity(unit, x, y, z, corner) - isoLevel) < AmbiguityThreshold {
+            return true
+        }
+    }
+    return false
+}
+
+// The case of every cube. Same layout as the marchingCubeCases buffer.
+func (f *Field) Classify() []int32 {
+    grid := f.Grid
+    cases := make([]int32, len(grid.IsoLevels)*grid.TotalCubeCount())
+    for s,isoLevel := range grid.IsoLevels {
+        for u,_ := range grid.UnitOffsets {
+            for z := 0; z < grid.ChunkSize[2]; z++ {
+                for y := 0; y < grid.ChunkSize[1]; y++ {
+                    for x := 0; x < grid.ChunkSize[0]; x++ {
+                        var cubeCase int32 = 0
+                        for corner := 0; corner < 8; corner++ {
+                            // Solid matter.
+                            if f.cornerDensity(u, x, y, z, corner) <= isoLevel {
+                                cubeCase |= 1 << uint(corner)
+                            }
+                        }
+                        cases[grid.CubeIndex(x, y, z, u, s)] = cubeCase
+                    }
+                }
+            }
+        }
+    }
+    return cases
+}
+
+type MeshTriangle [3]mgl32.Vec3
+
+// The result of one extraction. Same layout as the buffers of the compute shader.
+type Mesh struct {
+    Grid                Grid
+    Cases               []int32
+    // Index of the first triangle of every cube (triangleLayoutSizes after the prefix sum).
+    LayoutSizes         []int32
+    // All iso-surfaces, units and cubes in buffer order.
+    Triangles           []MeshTriangle
+    // Vertex normals in the same order. Empty, if they were not calculated.
+    Normals             []MeshTriangle
+    // Material IDs, colours and the baked ambient occlusion (1 is not occluded) of every vertex
+    // in the same order (pos.w, color and normal.w of the GPU vertices). Empty, if they were not calculated.
+    MaterialIDs         [][3]float32
+    Colors              [][3]mgl32.Vec4
+    Occlusion           [][3]float32
+    // Over all iso-surfaces.
+    UnitTriangleCounts  []int
+    // The edge of every vertex. Only known for meshes from Triangulate.
+    edges               [][3]vertexEdge
+}
+
+// The corners of the edge, a vertex was interpolated on, in world units.
+type vertexEdge struct {
+    p1, p2  mgl32.Vec3
+    // Position of the vertex on the edge from p1 (0) to p2 (1).
+    t       float32
+    // If the surface was solid at p1.
+    solid1  bool
+}
+
+// The triangles of one cube (index from Grid.CubeIndex) are Triangles[first:end].
+func (m *Mesh) CubeTriangles(i int) (first, end int) {
+    first = int(m.LayoutSizes[i])
+    end = len(m.Triangles)
+    if i+1 < len(m.LayoutSizes) {
+        end = int(m.LayoutSizes[i+1])
+    }
+    return first, end
+}
+
+// The triangles of one iso-surface are Triangles[first:end].
+func (m *Mesh) SurfaceTriangles(surface int) (first, end int) {
+    total := m.Grid.TotalCubeCount()
+    first, _ = m.CubeTriangles(surface*total)
+    _, end = m.CubeTriangles((surface+1)*total - 1)
+    return first, end
+}
+
+// The normalized gradient of the density, like calcNormalAt in the compute shader.
+func (m *Mesh) gradient(density Density, p mgl32.Vec3) mgl32.Vec3 {
+    // A hundredth of a cube.
+    d := 0.01 * m.Grid.CubeSize
+    normal := mgl32.Vec3 {
+        density(p.Add(mgl32.Vec3{d,0,0})) - density(p.Sub(mgl32.Vec3{d,0,0})),
+        density(p.Add(mgl32.Vec3{0,d,0})) - density(p.Sub(mgl32.Vec3{0,d,0})),
+        density(p.Add(mgl32.Vec3{0,0,d})) - density(p.Sub(mgl32.Vec3{0,0,d})),
+    }
+    return normal.Normalize()
+}
+
+// Normals from the partial derivatives of the density, like calcNormalAt in the compute shader.
+func (m *Mesh) CalculateNormals(density Density) {
+    m.Normals = make([]MeshTriangle, len(m.Triangles))
+    for t,triangle := range m.Triangles {
+        for v,p := range triangle {
+            m.Normals[t][v] = m.gradient(density, p)
+        }
+    }
+}
+
+// Material IDs and colours like densityInterpolation in the compute shader: The colour is
+// interpolated along the edge like the position, the ID is the one of the solid end of the edge.
+// Only for meshes from Triangulate, the GPU mesh already has them.
+func (m *Mesh) CalculateMaterials(materials Materials) {
+    m.MaterialIDs = make([][3]float32, len(m.Triangles))
+    m.Colors = make([][3]mgl32.Vec4, len(m.Triangles))
+    for t,_ := range m.Triangles {
+        for v,edge := range m.edges[t] {
+            m1, m2 := materials(edge.p1), materials(edge.p2)
+            m.Colors[t][v] = m1.Color.Add(m2.Color.Sub(m1.Color).Mul(edge.t))
+            m.MaterialIDs[t][v] = m2.ID
+            if edge.solid1 {
+                m.MaterialIDs[t][v] = m1.ID
+            }
+        }
+    }
+}
+
+// Ambient occlusion like calcOcclusionAt in the compute shader (BAKED_AO_RAYS rays, BAKED_AO_STEPS steps),
+// with the same rays. distance is aoDistance in world units.
+func (m *Mesh) CalculateOcclusion(density Density, rays, steps int, distance float32) {
+    m.Occlusion = make([][3]float32, len(m.Triangles))
+    for s,isoLevel := range m.Grid.IsoLevels {
+        first, end := m.SurfaceTriangles(s)
+        for t := first; t < end; t++ {
+            for v,p := range m.Triangles[t] {
+                m.Occlusion[t][v] = m.occlusionAt(density, p, m.gradient(density, p), isoLevel, rays, steps, distance)
+            }
+        }
+    }
+}
+
+func (m *Mesh) occlusionAt(density Density, pos, normal mgl32.Vec3, isoLevel float32, rays, steps int, distance float32) float32 {
+    if rays <= 0 {
+        return 1
+    }
+    helper := mgl32.Vec3{0,1,0}
+    if abs(normal[0]) < 0.9 {
+        helper = mgl32.Vec3{1,0,0}
+    }
+    tangent := normal.Cross(helper).Normalize()
+    bitangent := normal.Cross(tangent)
+    // Away from the surface itself. Otherwise flat ground occludes itself.
+    origin := pos.Add(normal.Mul(0.25 * m.Grid.CubeSize))
+
+    var occlusion, weights float32 = 0, 0
+    for i := 0; i < rays; i++ {
+        // Fibonacci spiral over the hemisphere, so the rays are evenly spread.
+        z := 1.0 - (float32(i)+0.5) / float32(rays)
+        r := float32(math.Sqrt(float64(1.0 - z*z)))
+        phi := float64(i) * 2.39996323
+        dir := tangent.Mul(float32(math.Cos(phi))*r).Add(bitangent.Mul(float32(math.Sin(phi))*r)).Add(normal.Mul(z))
+
+        // Cosine weighted. Rays along the normal matter most.
+        weights += z
+        for s := 0; s < steps; s++ {
+            t := float32(s+1) / float32(steps)
+            if density(origin.Add(dir.Mul(t*distance))) <= isoLevel {
+                occlusion += z * (1.0 - float32(s) / float32(steps))
+                break
+            }
+        }
+    }
+    return 1.0 - occlusion / weights
+}
+
+func (f *Field) interpolate(unit, x, y, z, edge int, isoLevel float32) (mgl32.Vec3, vertexEdge) {
+    c1, c2 := edgeCorners[edge][0], edgeCorners[edge][1]
+    d1, d2 := f.cornerDensity(unit, x, y, z, c1), f.cornerDensity(unit, x, y, z, c2)
+    t := (isoLevel - d1) / (d2 - d1)
+
+    o1, o2 := cornerOffsets[c1], cornerOffsets[c2]
+    var p mgl32.Vec3
+    for i := 0; i < 3; i++ {
+        p[i] = float32(o1[i]) + t*float32(o2[i]-o1[i])
+    }
+    cubePos := mgl32.Vec3{float32(x), float32(y), float32(z)}.Add(f.Grid.UnitOffsets[unit])
+    corner := func(o [3]int) mgl32.Vec3 {
+        return mgl32.Vec3{float32(o[0]), float32(o[1]), float32(o[2])}.Add(cubePos).Mul(f.Grid.CubeSize)
+    }
+    return p.Add(cubePos).Mul(f.Grid.CubeSize), vertexEdge{corner(o1), corner(o2), t, d1 <= isoLevel}
+}
+
+// Creates the triangles for the given cases. Usually the ones of Classify, but the cases
+// of the GPU can be used as well, so ambiguous cubes do not shift all following triangles.
+// Returns an error, if the tables are inconsistent for one of the cases.
+func Triangulate(field *Field, cases []int32, tables Tables) (*Mesh, error) {
+    grid := field.Grid
+    mesh := &Mesh {
+        Grid:               grid,
+        Cases:              cases,
+        LayoutSizes:        make([]int32, len(cases)),
+        UnitTriangleCounts: make([]int, len(grid.UnitOffsets)),
+    }
+
+    for s,isoLevel := range grid.IsoLevels {
+        for u,_ := range grid.UnitOffsets {
+            for z := 0; z < grid.ChunkSize[2]; z++ {
+                for y := 0; y < grid.ChunkSize[1]; y++ {
+                    for x := 0; x < grid.ChunkSize[0]; x++ {
+                        i := grid.CubeIndex(x, y, z, u, s)
+                        cubeCase := cases[i]
+                        mesh.LayoutSizes[i] = int32(len(mesh.Triangles))
+
+                        count := int(tables.CaseToNumPolys[cubeCase])
+                        for t := 0; t < 5; t++ {
+                            edges := tables.EdgeConnectList[15*cubeCase+3*int32(t):15*cubeCase+3*int32(t)+3]
+                            if (t < count) != (edges[0] != -1) {
+                                return nil, fmt.Errorf("case %v: caseToNumPolys is %v, but edgeConnectList has a different number of triangles", cubeCase, count)
+                            }
+                            if t >= count {
+                                break
+                            }
+
+                            var triangle MeshTriangle
+                            var triangleEdges [3]vertexEdge
+                            for v,edge := range edges {
+                                if edge < 0 || edge >= 12 {
+                                    return nil, fmt.Errorf("case %v: edge %v of triangle %v does not exist", cubeCase, edge, t)
+                                }
+                                // The surface only crosses edges between solid matter and no matter.
+                                c1, c2 := edgeCorners[edge][0], edgeCorners[edge][1]
+                                if (cubeCase >> uint(c1)) & 1 == (cubeCase >> uint(c2)) & 1 {
+                                    return nil, fmt.Errorf("case %v: triangle %v uses edge %v, which the surface does not cross", cubeCase, t, edge)
+                                }
+                                triangle[v], triangleEdges[v] = field.interpolate(u, x, y, z, int(edge), isoLevel)
+                            }
+                            mesh.Triangles = append(mesh.Triangles, triangle)
+                            mesh.edges = append(mesh.edges, triangleEdges)
+                            mesh.UnitTriangleCounts[u] += 1
+                        }
+                    }
+                }
+            }
+        }
+    }
+    return mesh, nil
+}
+
+// Classifies and triangulates the whole field.
+func Extract(grid Grid, density Density, tables Tables) (*Mesh, error) {
+    field := Sample(grid, density)
+    return Triangulate(field, field.Classify(), tables)
+}
+
+type Result struct {
+    // Cubes with a different case, where no corner is close to the iso-level.
+    CaseMismatches      int
+    // Cubes with a different case, where some corner is close to the iso-level. Expected.
+    AmbiguousCases      int
+    LayoutMismatches    int
+    // Units with a different number of triangles.
+    UnitMismatches      []int
+    // Vertices further away than the tolerance.
+    VertexMismatches    int
+    MaxVertexDistance   float32
+    TriangleCount       int
+    ReferenceCount      int
+    Passed              bool
+}
+
+func (r Result) String() string {
+    return fmt.Sprintf("%v triangles (reference %v), %v cases differ (%v ambiguous), %v layout sizes differ, %v units differ, %v vertices differ (max distance %.5f)",
+                       r.TriangleCount, r.ReferenceCount, r.CaseMismatches+r.AmbiguousCases, r.AmbiguousCases,
+                       r.LayoutMismatches, len(r.UnitMismatches), r.VertexMismatches, r.MaxVertexDistance)
+}
+
+// Compares the mesh of the GPU against the CPU extraction of the same field.
+// The reference is classified from the CPU densities, not the cases of the GPU. Cases of ambiguous
+// cubes may differ, so the triangles are compared cube by cube and those cubes are skipped.
+// The layout sizes, unit counts and the number of triangles of the GPU have to match its own cases.
+// The tables are the same on both sides, so they have to be checked on their own (CompareTables).
+func Compare(field *Field, gpu *Mesh, tables Tables, tolerance float32) (Result, error) {
+    grid := field.Grid
+    result := Result{TriangleCount: len(gpu.Triangles)}
+
+    cpuCases := field.Classify()
+    if len(gpu.Cases) != len(cpuCases) || len(gpu.LayoutSizes) != len(cpuCases) {
+        return result, fmt.Errorf("GPU mesh has %v cases and %v layout sizes, expected %v", len(gpu.Cases), len(gpu.LayoutSizes), len(cpuCases))
+    }
+    reference, err := Triangulate(field, cpuCases, tables)
+    if err != nil {
+        return result, err
+    }
+    result.ReferenceCount = len(reference.Triangles)
+
+    // The prefix sum over the cases of the GPU.
+    var gpuCount int32 = 0
+    unitCounts := make([]int, len(grid.UnitOffsets))
+    for s,_ := range grid.IsoLevels {
+        for u,_ := range grid.UnitOffsets {
+            for z := 0; z < grid.ChunkSize[2]; z++ {
+                for y := 0; y < grid.ChunkSize[1]; y++ {
+                    for x := 0; x < grid.ChunkSize[0]; x++ {
+                        i := grid.CubeIndex(x, y, z, u, s)
+                        if gpu.Cases[i] < 0 || gpu.Cases[i] > 255 {
+                            return result, fmt.Errorf("GPU cube %v has case %v", i, gpu.Cases[i])
+                        }
+                        if gpu.LayoutSizes[i] != gpuCount {
+                            result.LayoutMismatches += 1
+                        }
+                        count := tables.CaseToNumPolys[gpu.Cases[i]]
+                        gpuCount += count
+                        unitCounts[u] += int(count)
+
+                        if cpuCases[i] != gpu.Cases[i] {
+                            if field.Ambiguous(u, x, y, z, s) {
+                                result.AmbiguousCases += 1
+                            } else {
+                                result.CaseMismatches += 1
+                            }
+                            continue
+                        }
+
+                        // Only with a consistent layout, the triangles of the cube can be found.
+                        first, end := gpu.CubeTriangles(i)
+                        refFirst, refEnd := reference.CubeTriangles(i)
+                        if end - first != refEnd - refFirst || end > len(gpu.Triangles) {
+                            continue
+                        }
+                        for t := 0; t < end - first; t++ {
+                            for v := 0; v < 3; v++ {
+                                distance := gpu.Triangles[first+t][v].Sub(reference.Triangles[refFirst+t][v]).Len()
+                                result.MaxVertexDistance = max(result.MaxVertexDistance, distance)
+                                if distance > tolerance {
+                                    result.VertexMismatches += 1
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        }
+    }
+    for u,_ := range grid.UnitOffsets {
+        if u >= len(gpu.UnitTriangleCounts) || unitCounts[u] != gpu.UnitTriangleCounts[u] {
+            result.UnitMismatches = append(result.UnitMismatches, u)
+        }
+    }
+
+    result.Passed = result.CaseMismatches == 0 && result.LayoutMismatches == 0 && len(result.UnitMismatches) == 0 &&
+                    result.VertexMismatches == 0 && len(gpu.Triangles) == int(gpuCount)
+    return result, nil
+}
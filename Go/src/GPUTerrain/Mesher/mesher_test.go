@@ -0,0 +1,354 @@
+package mesher
+
+import (
+    "github.com/go-gl/mathgl/mgl32"
+    "strings"
+    "testing"
+)
+
+// One unit of one cube at the origin.
+var singleCube = Grid {
+    ChunkSize:   [3]int{1, 1, 1},
+    UnitOffsets: []mgl32.Vec3{{0, 0, 0}},
+    IsoLevels:   []float32{0},
+    CubeSize:    1,
+}
+
+// -1 (solid) at the given corners of the unit cube, 1 everywhere else.
+func solidCorners(corners ...int) Density {
+    return func(pos mgl32.Vec3) float32 {
+        for _,c := range corners {
+            o := cornerOffsets[c]
+            if pos == (mgl32.Vec3{float32(o[0]), float32(o[1]), float32(o[2])}) {
+                return -1
+            }
+        }
+        return 1
+    }
+}
+
+// The vertices of all triangles, independent of their order.
+func vertexSet(triangles []MeshTriangle) map[mgl32.Vec3]bool {
+    set := map[mgl32.Vec3]bool{}
+    for _,triangle := range triangles {
+        for _,v := range triangle {
+            set[v] = true
+        }
+    }
+    return set
+}
+
+func TestSingleCube(t *testing.T) {
+    tests := []struct {
+        name        string
+        corners     []int
+        cubeCase    int32
+        triangles   int
+        // With densities -1 and 1, every vertex is in the middle of its edge.
+        vertices    []mgl32.Vec3
+    }{
+        {"empty", nil, 0, 0, nil},
+        {"full", []int{0, 1, 2, 3, 4, 5, 6, 7}, 255, 0, nil},
+        {"corner 0", []int{0}, 1, 1, []mgl32.Vec3{{0, 0.5, 0}, {0.5, 0, 0}, {0, 0, 0.5}}},
+        {"corner 6", []int{6}, 64, 1, []mgl32.Vec3{{1, 0.5, 1}, {0.5, 1, 1}, {1, 1, 0.5}}},
+        // The bottom face (y = 0) is solid, so the surface is the plane y = 0.5.
+        {"bottom", []int{0, 3, 4, 7}, 1 | 8 | 16 | 128, 2, []mgl32.Vec3{{0, 0.5, 0}, {1, 0.5, 0}, {0, 0.5, 1}, {1, 0.5, 1}}},
+    }
+    for _,test := range tests {
+        mesh, err := Extract(singleCube, solidCorners(test.corners...), ShippedTables)
+        if err != nil {
+            t.Fatalf("%v: %v", test.name, err)
+        }
+        if mesh.Cases[0] != test.cubeCase {
+            t.Errorf("%v: case %v, want %v", test.name, mesh.Cases[0], test.cubeCase)
+        }
+        if len(mesh.Triangles) != test.triangles {
+            t.Errorf("%v: %v triangles, want %v", test.name, len(mesh.Triangles), test.triangles)
+        }
+        vertices := vertexSet(mesh.Triangles)
+        if len(vertices) != len(test.vertices) {
+            t.Errorf("%v: vertices %v, want %v", test.name, vertices, test.vertices)
+        }
+        for _,v := range test.vertices {
+            if !vertices[v] {
+                t.Errorf("%v: vertex %v is missing in %v", test.name, v, vertices)
+            }
+        }
+    }
+}
+
+// The surface normal points away from the solid corners (with the density gradient).
+func TestSingleCubeNormals(t *testing.T) {
+    plane := func(pos mgl32.Vec3) float32 { return pos[1] - 0.5 }
+    mesh, err := Extract(singleCube, plane, ShippedTables)
+    if err != nil {
+        t.Fatal(err)
+    }
+    mesh.CalculateNormals(plane)
+    for _,triangle := range mesh.Normals {
+        for _,n := range triangle {
+            if n.Sub(mgl32.Vec3{0, 1, 0}).Len() > 1e-3 {
+                t.Errorf("normal %v, want (0,1,0)", n)
+            }
+        }
+    }
+}
+
+// Unit offsets are in cubes, positions in world units.
+func TestSampleScale(t *testing.T) {
+    grid := Grid {
+        ChunkSize:   [3]int{2, 2, 2},
+        UnitOffsets: []mgl32.Vec3{{0, 0, 0}, {2, 0, 0}},
+        IsoLevels:   []float32{0},
+        CubeSize:    0.5,
+    }
+    field := Sample(grid, func(pos mgl32.Vec3) float32 { return pos[0] })
+    if d := field.Density(1, 2, 0, 0); d != 2.0 {
+        t.Errorf("density at corner 2 of unit 1 is %v, want (2+2)*0.5 = 2", d)
+    }
+    if d := field.Density(0, 1, 2, 2); d != 0.5 {
+        t.Errorf("density at corner 1 of unit 0 is %v, want 0.5", d)
+    }
+}
+
+// Every cube has its own index. x changes fastest, then y, z, the unit and the iso-surface.
+func TestCubeIndex(t *testing.T) {
+    grid := Grid {
+        ChunkSize:   [3]int{3, 2, 4},
+        UnitOffsets: make([]mgl32.Vec3, 2),
+        IsoLevels:   make([]float32, 3),
+    }
+    next := 0
+    for s := 0; s < 3; s++ {
+        for u := 0; u < 2; u++ {
+            for z := 0; z < 4; z++ {
+                for y := 0; y < 2; y++ {
+                    for x := 0; x < 3; x++ {
+                        if i := grid.CubeIndex(x, y, z, u, s); i != next {
+                            t.Fatalf("CubeIndex(%v,%v,%v, unit %v, surface %v) = %v, want %v", x, y, z, u, s, i, next)
+                        }
+                        next += 1
+                    }
+                }
+            }
+        }
+    }
+    if next != 3*grid.TotalCubeCount() {
+        t.Errorf("%v cubes, TotalCubeCount is %v per surface", next, grid.TotalCubeCount())
+    }
+}
+
+// A sphere through several units and two iso-surfaces.
+func sphereMesh(t *testing.T) (*Field, *Mesh) {
+    grid := Grid {
+        ChunkSize:   [3]int{4, 4, 4},
+        UnitOffsets: []mgl32.Vec3{{0, 0, 0}, {4, 0, 0}, {0, 4, 0}, {4, 4, 0}},
+        IsoLevels:   []float32{0, 1},
+        CubeSize:    1,
+    }
+    sphere := func(pos mgl32.Vec3) float32 { return ImplicitSphere(pos.Sub(mgl32.Vec3{4.1, 4.2, 1.9}), 2.7) }
+    field := Sample(grid, sphere)
+    mesh, err := Triangulate(field, field.Classify(), ShippedTables)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return field, mesh
+}
+
+// LayoutSizes is the exclusive prefix sum of the triangle counts, like triangleLayoutSizes after the scan.
+func TestLayoutPrefixSum(t *testing.T) {
+    _, mesh := sphereMesh(t)
+
+    var sum int32 = 0
+    for i,cubeCase := range mesh.Cases {
+        if mesh.LayoutSizes[i] != sum {
+            t.Fatalf("layout size of cube %v is %v, want %v", i, mesh.LayoutSizes[i], sum)
+        }
+        first, end := mesh.CubeTriangles(i)
+        if end - first != int(CaseToNumPolys[cubeCase]) {
+            t.Fatalf("cube %v has %v triangles, case %v has %v", i, end-first, cubeCase, CaseToNumPolys[cubeCase])
+        }
+        sum += CaseToNumPolys[cubeCase]
+    }
+    if int(sum) != len(mesh.Triangles) {
+        t.Errorf("%v triangles, the cases sum up to %v", len(mesh.Triangles), sum)
+    }
+
+    unitSum := 0
+    for _,count := range mesh.UnitTriangleCounts {
+        unitSum += count
+    }
+    if unitSum != len(mesh.Triangles) {
+        t.Errorf("units have %v triangles, the mesh %v", unitSum, len(mesh.Triangles))
+    }
+
+    // The surfaces follow each other without a gap.
+    first0, end0 := mesh.SurfaceTriangles(0)
+    first1, end1 := mesh.SurfaceTriangles(1)
+    if first0 != 0 || end0 != first1 || end1 != len(mesh.Triangles) || end0 == 0 || end1 == end0 {
+        t.Errorf("surfaces are [%v,%v) and [%v,%v) of %v triangles", first0, end0, first1, end1, len(mesh.Triangles))
+    }
+}
+
+func TestCompare(t *testing.T) {
+    field, mesh := sphereMesh(t)
+
+    result, err := Compare(field, mesh, ShippedTables, 1e-4)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !result.Passed {
+        t.Errorf("the mesh differs from itself: %v", result)
+    }
+
+    moved := *mesh
+    moved.Triangles = append([]MeshTriangle{}, mesh.Triangles...)
+    moved.Triangles[3][1] = moved.Triangles[3][1].Add(mgl32.Vec3{0, 0.1, 0})
+    result, err = Compare(field, &moved, ShippedTables, 1e-4)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if result.Passed || result.VertexMismatches != 1 {
+        t.Errorf("a moved vertex gives: %v, passed %v", result, result.Passed)
+    }
+}
+
+// A cube, that the GPU classifies differently, although no corner is close to the iso-level.
+// Its triangles are skipped, the following ones are still compared.
+func TestCompareCases(t *testing.T) {
+    field, mesh := sphereMesh(t)
+
+    changed := *mesh
+    changed.Cases = append([]int32{}, mesh.Cases...)
+    changed.LayoutSizes = append([]int32{}, mesh.LayoutSizes...)
+    changed.Triangles = append([]MeshTriangle{}, mesh.Triangles...)
+    i := 0
+    for mesh.Cases[i] == 0 || mesh.Cases[i] == 255 {
+        i += 1
+    }
+    first, end := mesh.CubeTriangles(i)
+    // The complement has the same number of triangles, so the layout stays the same.
+    changed.Cases[i] = 255 - mesh.Cases[i]
+    for t := first; t < end; t++ {
+        changed.Triangles[t][0], changed.Triangles[t][1] = changed.Triangles[t][1], changed.Triangles[t][0]
+    }
+
+    result, err := Compare(field, &changed, ShippedTables, 1e-4)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if result.Passed || result.CaseMismatches != 1 || result.VertexMismatches != 0 || result.LayoutMismatches != 0 {
+        t.Errorf("a changed case gives: %v, passed %v", result, result.Passed)
+    }
+}
+
+// Layout sizes, that are no prefix sum of the cases of the GPU.
+func TestCompareLayout(t *testing.T) {
+    field, mesh := sphereMesh(t)
+
+    changed := *mesh
+    changed.LayoutSizes = append([]int32{}, mesh.LayoutSizes...)
+    changed.LayoutSizes[len(changed.LayoutSizes)/2] += 1
+    changed.UnitTriangleCounts = append([]int{}, mesh.UnitTriangleCounts...)
+    changed.UnitTriangleCounts[1] -= 1
+
+    result, err := Compare(field, &changed, ShippedTables, 1e-4)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if result.Passed || result.LayoutMismatches != 1 || len(result.UnitMismatches) != 1 || result.UnitMismatches[0] != 1 {
+        t.Errorf("a wrong layout size and unit count give: %v, passed %v", result, result.Passed)
+    }
+
+    // One triangle less than the cases need.
+    changed = *mesh
+    changed.Triangles = mesh.Triangles[:len(mesh.Triangles)-1]
+    if result, _ := Compare(field, &changed, ShippedTables, 1e-4); result.Passed {
+        t.Errorf("a missing triangle passed: %v", result)
+    }
+}
+
+func TestTriangulateErrors(t *testing.T) {
+    field := Sample(singleCube, solidCorners(0))
+    cases := field.Classify()
+
+    tests := []struct {
+        name    string
+        change  func(tables *Tables)
+        err     string
+    }{
+        {"count too high", func(tables *Tables) { tables.CaseToNumPolys[1] = 2 }, "different number of triangles"},
+        {"count too low", func(tables *Tables) { tables.CaseToNumPolys[1] = 0 }, "different number of triangles"},
+        {"missing edge", func(tables *Tables) { tables.EdgeConnectList[15+2] = 12 }, "does not exist"},
+        {"uncrossed edge", func(tables *Tables) { tables.EdgeConnectList[15+2] = 5 }, "does not cross"},
+    }
+    for _,test := range tests {
+        tables := copyTables(ShippedTables)
+        test.change(&tables)
+        _, err := Triangulate(field, cases, tables)
+        if err == nil || !strings.Contains(err.Error(), test.err) {
+            t.Errorf("%v: error %v, want one with %q", test.name, err, test.err)
+        }
+    }
+}
+
+// The ID of the solid end of the edge and the colour interpolated like the position.
+func TestCalculateMaterials(t *testing.T) {
+    mesh, err := Extract(singleCube, solidCorners(0), ShippedTables)
+    if err != nil {
+        t.Fatal(err)
+    }
+    // Red with ID 2 at corner 0, blue with ID 5 everywhere else.
+    mesh.CalculateMaterials(func(pos mgl32.Vec3) Material {
+        if pos == (mgl32.Vec3{0, 0, 0}) {
+            return Material{mgl32.Vec4{1, 0, 0, 1}, 2}
+        }
+        return Material{mgl32.Vec4{0, 0, 1, 1}, 5}
+    })
+    for v := 0; v < 3; v++ {
+        if id := mesh.MaterialIDs[0][v]; id != 2 {
+            t.Errorf("vertex %v has material %v, want the solid corner's 2", v, id)
+        }
+        if c := mesh.Colors[0][v]; c != (mgl32.Vec4{0.5, 0, 0.5, 1}) {
+            t.Errorf("vertex %v has colour %v, want the middle of the edge", v, c)
+        }
+    }
+}
+
+func TestCalculateOcclusion(t *testing.T) {
+    grid := Grid {
+        ChunkSize:   [3]int{4, 4, 4},
+        UnitOffsets: []mgl32.Vec3{{0, 0, 0}},
+        IsoLevels:   []float32{0},
+        CubeSize:    1,
+    }
+    ground := func(pos mgl32.Vec3) float32 { return pos[1] - 1.5 }
+    // The same ground with a ceiling 1.5 above it.
+    cave := func(pos mgl32.Vec3) float32 { return -ImplicitDifference(pos[1] - 3, pos[1] - 1.5) }
+
+    mesh, err := Extract(grid, ground, ShippedTables)
+    if err != nil {
+        t.Fatal(err)
+    }
+    mesh.CalculateOcclusion(ground, 12, 6, 4)
+    for _,occlusion := range mesh.Occlusion {
+        for _,o := range occlusion {
+            if o != 1 {
+                t.Fatalf("open ground has an occlusion of %v, want 1", o)
+            }
+        }
+    }
+
+    // Only the triangles on the ground, not the ceiling.
+    mesh.CalculateOcclusion(cave, 12, 6, 4)
+    for _,o := range mesh.Occlusion[0] {
+        if o >= 0.9 || o < 0 {
+            t.Errorf("ground under a ceiling has an occlusion of %v", o)
+        }
+    }
+
+    mesh.CalculateOcclusion(cave, 0, 6, 4)
+    if mesh.Occlusion[0][0] != 1 {
+        t.Errorf("occlusion without rays is %v, want 1", mesh.Occlusion[0][0])
+    }
+}
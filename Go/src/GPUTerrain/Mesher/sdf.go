@@ -0,0 +1,54 @@
+package mesher
+
+// CPU versions of the implicit primitives in sdf.glsl.
+// Negative is inside, positive is outside and 0 is the surface.
+
+import (
+    "github.com/go-gl/mathgl/mgl32"
+    "math"
+)
+
+func abs(f float32) float32 {
+    return float32(math.Abs(float64(f)))
+}
+func min(a, b float32) float32 {
+    return float32(math.Min(float64(a), float64(b)))
+}
+func max(a, b float32) float32 {
+    return float32(math.Max(float64(a), float64(b)))
+}
+
+func ImplicitSphere(p mgl32.Vec3, r float32) float32 {
+    return p[0]*p[0] + p[1]*p[1] + p[2]*p[2] - r*r
+}
+
+func ImplicitCube(p mgl32.Vec3, r float32) float32 {
+    return max(abs(p[0]), max(abs(p[1]), abs(p[2]))) - r
+}
+
+// Infinite cylinders along the x, y and z axis.
+func ImplicitCylinderX(p mgl32.Vec3, r float32) float32 {
+    return p[2]*p[2] + p[1]*p[1] - r*r
+}
+func ImplicitCylinderY(p mgl32.Vec3, r float32) float32 {
+    return p[0]*p[0] + p[2]*p[2] - r*r
+}
+func ImplicitCylinderZ(p mgl32.Vec3, r float32) float32 {
+    return p[0]*p[0] + p[1]*p[1] - r*r
+}
+
+func ImplicitUnion(d1, d2 float32) float32 {
+    return min(d1, d2)
+}
+func ImplicitIntersection(d1, d2 float32) float32 {
+    return max(d1, d2)
+}
+func ImplicitDifference(d1, d2 float32) float32 {
+    return max(d1, -d2)
+}
+
+// Smooth union, blending both surfaces together.
+func DensityUnion(d1, d2 float32) float32 {
+    var b float64 = 0.4
+    return float32(-math.Exp(-b*float64(d1)) - math.Exp(-b*float64(d2)) + 1.0)
+}
@@ -0,0 +1,208 @@
+package noise
+
+// CPU implementations of the noise functions in marchingCubes.comp.
+// Every function does exactly the same operations in the same order as the GLSL version,
+// so the CPU side can be used to validate the terrain that is created on the GPU.
+// All lattice hashing is done on uint32 and is bit-for-bit identical to the GPU.
+// The floating point parts are float32 on both sides but drivers may contract
+// multiply-adds or use less precise sin/sqrt, so results only match within a tolerance.
+// The mesh test (-meshtest) compares the GPU terrain against the CPU one with it.
+
+import (
+    "github.com/go-gl/mathgl/mgl32"
+    "math"
+)
+
+// The 12 edges of a cube. Same order as noiseGradients in the shader.
+var gradients = [12]mgl32.Vec3 {
+    {1,1,0}, {-1,1,0}, {1,-1,0}, {-1,-1,0},
+    {1,0,1}, {-1,0,1}, {1,0,-1}, {-1,0,-1},
+    {0,1,1}, {0,-1,1}, {0,1,-1}, {0,-1,-1},
+}
+
+// Integer hash with good avalanche behaviour (lowbias32).
+// Only uses 32bit unsigned integer operations, so the GPU gets the same result.
+func Hash(x uint32) uint32 {
+    x ^= x >> 16
+    x *= 0x7feb352d
+    x ^= x >> 15
+    x *= 0x846ca68b
+    x ^= x >> 16
+    return x
+}
+
+func hash3(x, y, z int32, seed uint32) uint32 {
+    return Hash(uint32(x) ^ Hash(uint32(y) ^ Hash(uint32(z) ^ Hash(seed))))
+}
+
+// A number in [0,1) from the lower 24 bit of the hash. Exactly representable as float32.
+func hashToFloat(h uint32) float32 {
+    return float32(h & 0xffffff) / 16777216.0
+}
+
+func floor(f float32) float32 {
+    return float32(math.Floor(float64(f)))
+}
+
+func fade(t float32) float32 {
+    return t*t*t*(t*(t*6.0 - 15.0) + 10.0)
+}
+
+func mix(a, b, t float32) float32 {
+    return a + (b - a)*t
+}
+
+func gradientDot(h uint32, x, y, z float32) float32 {
+    g := gradients[h%12]
+    return g[0]*x + g[1]*y + g[2]*z
+}
+
+// Classic (improved) Perlin gradient noise. Roughly in [-1,1].
+func Gradient(p mgl32.Vec3, seed uint32) float32 {
+    fx, fy, fz := floor(p[0]), floor(p[1]), floor(p[2])
+    ix, iy, iz := int32(fx), int32(fy), int32(fz)
+    x, y, z := p[0]-fx, p[1]-fy, p[2]-fz
+
+    n000 := gradientDot(hash3(ix,   iy,   iz,   seed), x,     y,     z)
+    n100 := gradientDot(hash3(ix+1, iy,   iz,   seed), x-1.0, y,     z)
+    n010 := gradientDot(hash3(ix,   iy+1, iz,   seed), x,     y-1.0, z)
+    n110 := gradientDot(hash3(ix+1, iy+1, iz,   seed), x-1.0, y-1.0, z)
+    n001 := gradientDot(hash3(ix,   iy,   iz+1, seed), x,     y,     z-1.0)
+    n101 := gradientDot(hash3(ix+1, iy,   iz+1, seed), x-1.0, y,     z-1.0)
+    n011 := gradientDot(hash3(ix,   iy+1, iz+1, seed), x,     y-1.0, z-1.0)
+    n111 := gradientDot(hash3(ix+1, iy+1, iz+1, seed), x-1.0, y-1.0, z-1.0)
+
+    u, v, w := fade(x), fade(y), fade(z)
+
+    nx00 := mix(n000, n100, u)
+    nx10 := mix(n010, n110, u)
+    nx01 := mix(n001, n101, u)
+    nx11 := mix(n011, n111, u)
+    nxy0 := mix(nx00, nx10, v)
+    nxy1 := mix(nx01, nx11, v)
+
+    return mix(nxy0, nxy1, w)
+}
+
+func simplexCorner(x, y, z float32, h uint32) float32 {
+    t := float32(0.6) - x*x - y*y - z*z
+    if t <= 0.0 {
+        return 0.0
+    }
+    t *= t
+    return t*t*gradientDot(h, x, y, z)
+}
+
+// 3D simplex noise. Roughly in [-1,1].
+func Simplex(p mgl32.Vec3, seed uint32) float32 {
+    const F3 = float32(1.0/3.0)
+    const G3 = float32(1.0/6.0)
+
+    s := (p[0] + p[1] + p[2])*F3
+    fi, fj, fk := floor(p[0]+s), floor(p[1]+s), floor(p[2]+s)
+    t := (fi + fj + fk)*G3
+
+    // Distances from the first corner of the simplex
+    x0, y0, z0 := p[0]-(fi-t), p[1]-(fj-t), p[2]-(fk-t)
+
+    // Which of the six simplices we are in.
+    var i1, j1, k1, i2, j2, k2 int32
+    if x0 >= y0 {
+        switch {
+            case y0 >= z0: i1, j1, k1, i2, j2, k2 = 1,0,0, 1,1,0
+            case x0 >= z0: i1, j1, k1, i2, j2, k2 = 1,0,0, 1,0,1
+            default:       i1, j1, k1, i2, j2, k2 = 0,0,1, 1,0,1
+        }
+    } else {
+        switch {
+            case y0 < z0:  i1, j1, k1, i2, j2, k2 = 0,0,1, 0,1,1
+            case x0 < z0:  i1, j1, k1, i2, j2, k2 = 0,1,0, 0,1,1
+            default:       i1, j1, k1, i2, j2, k2 = 0,1,0, 1,1,0
+        }
+    }
+
+    x1, y1, z1 := x0-float32(i1)+G3,     y0-float32(j1)+G3,     z0-float32(k1)+G3
+    x2, y2, z2 := x0-float32(i2)+2.0*G3, y0-float32(j2)+2.0*G3, z0-float32(k2)+2.0*G3
+    x3, y3, z3 := x0-1.0+3.0*G3,         y0-1.0+3.0*G3,         z0-1.0+3.0*G3
+
+    i, j, k := int32(fi), int32(fj), int32(fk)
+
+    n := simplexCorner(x0, y0, z0, hash3(i,    j,    k,    seed))
+    n += simplexCorner(x1, y1, z1, hash3(i+i1, j+j1, k+k1, seed))
+    n += simplexCorner(x2, y2, z2, hash3(i+i2, j+j2, k+k2, seed))
+    n += simplexCorner(x3, y3, z3, hash3(i+1,  j+1,  k+1,  seed))
+
+    return 32.0*n
+}
+
+// Cellular noise. Returns the distance to the closest (f1) and second closest (f2) feature point.
+// There is exactly one feature point per unit cell.
+func Worley(p mgl32.Vec3, seed uint32) (float32, float32) {
+    fx, fy, fz := floor(p[0]), floor(p[1]), floor(p[2])
+    ix, iy, iz := int32(fx), int32(fy), int32(fz)
+
+    var f1, f2 float32 = 100.0, 100.0
+
+    for z := int32(-1); z <= 1; z++ {
+        for y := int32(-1); y <= 1; y++ {
+            for x := int32(-1); x <= 1; x++ {
+                h := hash3(ix+x, iy+y, iz+z, seed)
+                // Feature point inside the neighbouring cell
+                px := float32(ix+x) + hashToFloat(h)
+                py := float32(iy+y) + hashToFloat(Hash(h))
+                pz := float32(iz+z) + hashToFloat(Hash(Hash(h)))
+
+                dx, dy, dz := px-p[0], py-p[1], pz-p[2]
+                d := dx*dx + dy*dy + dz*dz
+                if d < f1 {
+                    f2 = f1
+                    f1 = d
+                } else if d < f2 {
+                    f2 = d
+                }
+            }
+        }
+    }
+
+    return float32(math.Sqrt(float64(f1))), float32(math.Sqrt(float64(f2)))
+}
+
+// Fractal brownian motion over gradient noise. Every octave uses its own seed.
+func Fbm(p mgl32.Vec3, seed uint32, octaves int, lacunarity, gain float32) float32 {
+    var sum       float32 = 0.0
+    var amplitude float32 = 0.5
+    for i := 0; i < octaves; i++ {
+        sum += amplitude * Gradient(p, seed+uint32(i))
+        p = p.Mul(lacunarity)
+        amplitude *= gain
+    }
+    return sum
+}
+
+// Ridged multifractal. Sharp ridges where the gradient noise crosses zero.
+// Every octave is weighted by the previous one, so the valleys stay smooth.
+func Ridged(p mgl32.Vec3, seed uint32, octaves int, lacunarity, gain, offset float32) float32 {
+    var sum       float32 = 0.0
+    var amplitude float32 = 0.5
+    var previous  float32 = 1.0
+    for i := 0; i < octaves; i++ {
+        n := offset - float32(math.Abs(float64(Gradient(p, seed+uint32(i)))))
+        n *= n
+        sum += n * amplitude * previous
+        previous = n
+        p = p.Mul(lacunarity)
+        amplitude *= gain
+    }
+    return sum
+}
+
+// Offsets p by three independent fBm values. Sampling any noise at the result
+// instead of p gives the typical twisted look of domain warping.
+func DomainWarp(p mgl32.Vec3, seed uint32, octaves int, strength float32) mgl32.Vec3 {
+    q := mgl32.Vec3 {
+        Fbm(p,                             seed,   octaves, 2.0, 0.5),
+        Fbm(p.Add(mgl32.Vec3{5.2,1.3,2.8}), seed+1, octaves, 2.0, 0.5),
+        Fbm(p.Add(mgl32.Vec3{1.7,9.2,4.1}), seed+2, octaves, 2.0, 0.5),
+    }
+    return p.Add(q.Mul(strength))
+}
@@ -0,0 +1,156 @@
+package noise
+
+import (
+    "github.com/go-gl/mathgl/mgl32"
+    "math"
+    "math/rand"
+    "testing"
+)
+
+// Go may fuse multiply-adds on some architectures, so the float results are compared with a small epsilon.
+// The hashes are integer only and have to match exactly.
+const epsilon = 1e-5
+
+func near(a, b float32) bool {
+    return math.Abs(float64(a - b)) <= epsilon
+}
+
+func nearVec(a, b mgl32.Vec3) bool {
+    return near(a[0], b[0]) && near(a[1], b[1]) && near(a[2], b[2])
+}
+
+// Random points away from the origin, so negative and large lattice coordinates are covered.
+func randomPoints(count int) []mgl32.Vec3 {
+    random := rand.New(rand.NewSource(1))
+    points := make([]mgl32.Vec3, count)
+    for i,_ := range points {
+        points[i] = mgl32.Vec3{random.Float32()*200-100, random.Float32()*200-100, random.Float32()*200-100}
+    }
+    return points
+}
+
+func TestHash(t *testing.T) {
+    tests := []struct {
+        x, want uint32
+    }{
+        {0x0, 0x0},
+        {0x1, 0x688990c0},
+        {0x2a, 0x172733c2},
+        {0xffffffff, 0x6768824a},
+    }
+    for _,test := range tests {
+        if got := Hash(test.x); got != test.want {
+            t.Errorf("Hash(%#x) = %#x, want %#x", test.x, got, test.want)
+        }
+    }
+}
+
+// Seed 7. noise.glsl has to stay in sync with these functions, so changing them changes the GPU terrain as well.
+func TestReferenceValues(t *testing.T) {
+    tests := []struct {
+        p                       mgl32.Vec3
+        gradient, simplex       float32
+        f1, f2                  float32
+        fbm, ridged             float32
+        warped                  mgl32.Vec3
+    }{
+        {mgl32.Vec3{0.5, 0.25, 0.75}, -0.011976242, -0.28423423, 0.61884314, 1.0020496, 0.025261879, 0.86439764, mgl32.Vec3{0.6010475, 0.053966954, 1.3824894}},
+        {mgl32.Vec3{12.3, -4.7, 8.1},  0.3372663,    0.36260065, 0.52084905, 0.7375432, 0.140467,    0.38593826, mgl32.Vec3{12.730653, -4.059498, 8.434527}},
+        {mgl32.Vec3{-3.2, 7.9, -0.4},  0.2477439,    0.882486,   0.50784177, 0.7189083, 0.10130766,  0.41853786, mgl32.Vec3{-2.6642318, 7.5761666, -1.1845239}},
+    }
+    for _,test := range tests {
+        if got := Gradient(test.p, 7); !near(got, test.gradient) {
+            t.Errorf("Gradient(%v) = %v, want %v", test.p, got, test.gradient)
+        }
+        if got := Simplex(test.p, 7); !near(got, test.simplex) {
+            t.Errorf("Simplex(%v) = %v, want %v", test.p, got, test.simplex)
+        }
+        if f1, f2 := Worley(test.p, 7); !near(f1, test.f1) || !near(f2, test.f2) {
+            t.Errorf("Worley(%v) = %v, %v, want %v, %v", test.p, f1, f2, test.f1, test.f2)
+        }
+        if got := Fbm(test.p, 7, 5, 2.0, 0.5); !near(got, test.fbm) {
+            t.Errorf("Fbm(%v) = %v, want %v", test.p, got, test.fbm)
+        }
+        if got := Ridged(test.p, 7, 5, 2.0, 0.5, 1.0); !near(got, test.ridged) {
+            t.Errorf("Ridged(%v) = %v, want %v", test.p, got, test.ridged)
+        }
+        if got := DomainWarp(test.p, 7, 3, 4.0); !nearVec(got, test.warped) {
+            t.Errorf("DomainWarp(%v) = %v, want %v", test.p, got, test.warped)
+        }
+    }
+}
+
+func TestRanges(t *testing.T) {
+    for _,p := range randomPoints(20000) {
+        if g := Gradient(p, 3); g < -1.0 || g > 1.0 {
+            t.Fatalf("Gradient(%v) = %v is outside of [-1,1]", p, g)
+        }
+        if s := Simplex(p, 3); s < -1.0 || s > 1.0 {
+            t.Fatalf("Simplex(%v) = %v is outside of [-1,1]", p, s)
+        }
+        // The feature point of the own cell is at most the cell diagonal away.
+        if f1, f2 := Worley(p, 3); f1 < 0 || f1 > float32(math.Sqrt(3)) || f2 < f1 {
+            t.Fatalf("Worley(%v) = %v, %v, want 0 <= f1 <= sqrt(3) and f1 <= f2", p, f1, f2)
+        }
+        // The amplitudes 0.5, 0.25, ... sum up to less than 1.
+        if f := Fbm(p, 3, 6, 2.0, 0.5); f < -1.0 || f > 1.0 {
+            t.Fatalf("Fbm(%v) = %v is outside of [-1,1]", p, f)
+        }
+        if r := Ridged(p, 3, 6, 2.0, 0.5, 1.0); r < 0 || r > 1.0 {
+            t.Fatalf("Ridged(%v) = %v is outside of [0,1]", p, r)
+        }
+    }
+}
+
+// Gradient noise is zero at every lattice point, with every seed. With a lacunarity of 2,
+// every octave of fBm is sampled at lattice points again. Ridged noise then only sees offset².
+func TestLattice(t *testing.T) {
+    for _,p := range randomPoints(1000) {
+        lattice := mgl32.Vec3{floor(p[0]), floor(p[1]), floor(p[2])}
+        seed := Hash(uint32(int32(p[0])))
+        if g := Gradient(lattice, seed); g != 0 {
+            t.Fatalf("Gradient(%v) = %v, want 0", lattice, g)
+        }
+        if f := Fbm(lattice, seed, 5, 2.0, 0.5); f != 0 {
+            t.Fatalf("Fbm(%v) = %v, want 0", lattice, f)
+        }
+        // 0.5 + 0.25 + 0.125 with offset 1.
+        if r := Ridged(lattice, seed, 3, 2.0, 0.5, 1.0); !near(r, 0.875) {
+            t.Fatalf("Ridged(%v) = %v, want 0.875", lattice, r)
+        }
+    }
+}
+
+// The noise has no seams at the cell borders.
+func TestContinuity(t *testing.T) {
+    const step = 1e-3
+    functions := map[string]func(mgl32.Vec3) float32 {
+        "Gradient": func(p mgl32.Vec3) float32 { return Gradient(p, 5) },
+        "Simplex":  func(p mgl32.Vec3) float32 { return Simplex(p, 5) },
+        "Worley":   func(p mgl32.Vec3) float32 { f1, _ := Worley(p, 5); return f1 },
+    }
+    for name,f := range functions {
+        for _,p := range randomPoints(1000) {
+            // Just before and after an integer x.
+            border := mgl32.Vec3{floor(p[0]) + 1.0, p[1], p[2]}
+            before := f(border.Sub(mgl32.Vec3{step/2, 0, 0}))
+            after := f(border.Add(mgl32.Vec3{step/2, 0, 0}))
+            if math.Abs(float64(after - before)) > 0.05 {
+                t.Fatalf("%v jumps from %v to %v at %v", name, before, after, border)
+            }
+        }
+    }
+}
+
+func TestSeeds(t *testing.T) {
+    p := mgl32.Vec3{3.3, 1.7, -2.1}
+    if Gradient(p, 1) == Gradient(p, 2) {
+        t.Errorf("Gradient(%v) does not depend on the seed", p)
+    }
+    if Simplex(p, 1) == Simplex(p, 2) {
+        t.Errorf("Simplex(%v) does not depend on the seed", p)
+    }
+    if got := DomainWarp(p, 1, 3, 0.0); got != p {
+        t.Errorf("DomainWarp(%v) with strength 0 = %v, want %v", p, got, p)
+    }
+}
@@ -0,0 +1,311 @@
+package opengl
+
+import (
+    . "GPUTerrain/Mesher"
+    "github.com/go-gl/gl/v4.5-core/gl"
+    "github.com/go-gl/mathgl/mgl32"
+    "fmt"
+    "unsafe"
+)
+
+// Same layout (std430) as Vertex in marchingCubes.comp.
+type Vertex struct {
+    // w is the material ID from the density function (see materials.glsl). -1 for none.
+    Pos      mgl32.Vec4
+    Normal   mgl32.Vec4
+    // Blended over the surface by its alpha.
+    Color    mgl32.Vec4
+}
+
+// Per-unit data for the compute shader. Same layout (std430) as Unit in marchingCubes.comp.
+type UnitOffset struct {
+    PositionOffset      mgl32.Vec4
+    CubeIndexOffset     int32
+    _                   [3]int32
+}
+
+// All buffers of marchingCubes.comp for one grid of units and iso-surfaces.
+//
+// Extract runs the two passes of the shader: The first one writes the case and triangle count of every cube.
+// After the prefix sum over the counts, the second one writes all triangles without gaps into the position buffer.
+type MarchingCubesBuffers struct {
+    Grid                        Grid
+    // Has to match WORK_GROUP_SIZE_* of the compiled shader.
+    WorkGroupSize               [3]int
+    // The triangles of the last run.
+    TriangleCount               int
+    // How many triangles fit into the position buffer. It grows, if a run creates more.
+    TriangleCapacity            int
+    // This arraybuffer is is main handle to the calculated positions on the GPU.
+    PositionArrayBuffer         uint32
+    // The vertex array of PositionArrayBuffer for rendering.
+    PositionVertexBuffer        uint32
+    // The buffer, the first run of marching cubes writes the triangle count into, they like to generate.
+    TriangleLayoutSizesBuffer   uint32
+    // The case of every cube from the first run.
+    CasesBuffer                 uint32
+    // Offsets of all units, so all of them are calculated with one dispatch.
+    UnitOffsetBuffer            uint32
+    CaseToNumPolysBuffer        uint32
+    EdgeConnectListBuffer       uint32
+    // Index of the first triangle of every cube after the prefix sum. A copy of TriangleLayoutSizesBuffer.
+    layoutSizes                 []int32
+}
+
+// Creates all buffers. The position buffer starts with room for trianglesPerCube triangles per cube.
+func NewMarchingCubesBuffers(grid Grid, workGroupSize [3]int, trianglesPerCube float32) *MarchingCubesBuffers {
+    // Every iso-surface needs its own cases and layout sizes.
+    isoCubeCount := len(grid.IsoLevels) * grid.TotalCubeCount()
+
+    m := &MarchingCubesBuffers {
+        Grid:          grid,
+        WorkGroupSize: workGroupSize,
+        layoutSizes:   make([]int32, isoCubeCount),
+    }
+
+    m.CaseToNumPolysBuffer = createStorageBuffer(len(CaseToNumPolys)*int(unsafe.Sizeof(int32(0))), gl.Ptr(&CaseToNumPolys[0]), gl.STATIC_READ)
+    // List of 256 * 5 * vec3()
+    m.EdgeConnectListBuffer = createStorageBuffer(len(EdgeConnectList)*int(unsafe.Sizeof(int32(0))), gl.Ptr(&EdgeConnectList[0]), gl.STATIC_READ)
+
+    // Each small cube writes into this buffer, how many triangles it wants to create.
+    // Using this information, we can later fill the position buffer up, without having
+    // empty positions.
+    m.TriangleLayoutSizesBuffer = createStorageBuffer(isoCubeCount*int(unsafe.Sizeof(int32(0))), gl.Ptr(&m.layoutSizes[0]), gl.DYNAMIC_COPY)
+    m.CasesBuffer = createStorageBuffer(isoCubeCount*int(unsafe.Sizeof(int32(0))), gl.Ptr(&m.layoutSizes[0]), gl.STATIC_READ)
+
+    offsets := make([]UnitOffset, len(grid.UnitOffsets))
+    for i,offset := range grid.UnitOffsets {
+        offsets[i] = UnitOffset {
+            PositionOffset:  offset.Vec4(0),
+            CubeIndexOffset: int32(i * grid.CubesPerUnit()),
+        }
+    }
+    m.UnitOffsetBuffer = createStorageBuffer(len(offsets)*int(unsafe.Sizeof(UnitOffset{})), gl.Ptr(&offsets[0].PositionOffset[0]), gl.STATIC_READ)
+
+    m.createPositionBuffers(int(float32(isoCubeCount) * trianglesPerCube))
+
+    return m
+}
+
+func createStorageBuffer(size int, data unsafe.Pointer, usage uint32) uint32 {
+    var buffer uint32
+    gl.GenBuffers    (1, &buffer);
+    gl.BindBuffer    (gl.ARRAY_BUFFER, buffer);
+    gl.BufferData    (gl.ARRAY_BUFFER, size, data, usage);
+    gl.BindBuffer    (gl.ARRAY_BUFFER, 0);
+    return buffer
+}
+
+// Here, the actual triangles are calculated and written into by the second marching cube shader invocation.
+// This buffer is later used for rendering!
+func (m *MarchingCubesBuffers) createPositionBuffers(triangleCapacity int) {
+
+    emptyVec := mgl32.Vec4{}
+    vec4Size := int(unsafe.Sizeof(emptyVec))
+
+    emptyVertex := Vertex{}
+    stride := int(unsafe.Sizeof(emptyVertex))
+
+    triangleSize := 3*stride
+
+    // Only allocated. The compute shader writes all triangles.
+    // An empty buffer can not be bound as storage buffer.
+    if triangleCapacity < 1 {
+        triangleCapacity = 1
+    }
+    m.TriangleCapacity = triangleCapacity
+    m.PositionArrayBuffer = createStorageBuffer(triangleCapacity * triangleSize, nil, gl.DYNAMIC_DRAW)
+
+    gl.GenVertexArrays(1, &m.PositionVertexBuffer)
+    gl.BindVertexArray(m.PositionVertexBuffer)
+    gl.BindBuffer(gl.ARRAY_BUFFER, m.PositionArrayBuffer)
+
+    gl.EnableVertexAttribArray(0)
+    gl.VertexAttribPointer(0, 4, gl.FLOAT, false, int32(stride), gl.PtrOffset(0))
+    gl.EnableVertexAttribArray(1)
+    // If adding more attributes to a vertex, change the Offset and potentially stride here!
+    gl.VertexAttribPointer(1, 4, gl.FLOAT, true, int32(stride), gl.PtrOffset(vec4Size))
+    gl.EnableVertexAttribArray(2)
+    gl.VertexAttribPointer(2, 4, gl.FLOAT, false, int32(stride), gl.PtrOffset(2*vec4Size))
+
+    gl.BindVertexArray(0)
+    gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+}
+
+func divideRoundUp(a, b int) uint32 {
+    return uint32((a + b - 1) / b)
+}
+
+// Enough work groups to cover all units and iso-surfaces. The shader ignores the invocations outside of a unit.
+// The z-dimension of the work groups is split into one block per unit and surface.
+func (m *MarchingCubesBuffers) workGroups() (uint32, uint32, uint32) {
+    chunk, size := m.Grid.ChunkSize, m.WorkGroupSize
+    groupsZ := divideRoundUp(chunk[2], size[2]) * uint32(len(m.Grid.UnitOffsets) * len(m.Grid.IsoLevels))
+    return divideRoundUp(chunk[0], size[0]), divideRoundUp(chunk[1], size[1]), groupsZ
+}
+
+// Returns an error, if the units and iso-surfaces do not fit into one dispatch.
+func (m *MarchingCubesBuffers) CheckDispatchLimits() error {
+    var maxWorkGroupCountZ int32
+    gl.GetIntegeri_v(gl.MAX_COMPUTE_WORK_GROUP_COUNT, 2, &maxWorkGroupCountZ)
+    if _, _, groupsZ := m.workGroups(); int(groupsZ) > int(maxWorkGroupCountZ) {
+        return fmt.Errorf("too many units and iso-surfaces for one dispatch (at most %v work groups in z)", maxWorkGroupCountZ)
+    }
+    return nil
+}
+
+// Runs both passes of the compute shader. The program has to be in use and all uniforms
+// of the density function have to be set already.
+func (m *MarchingCubesBuffers) Extract(program *Program) error {
+
+    bindings := []struct{name string; buffer uint32} {
+        {"caseToNumPolys",      m.CaseToNumPolysBuffer},
+        {"edgeConnectList",     m.EdgeConnectListBuffer},
+        {"unitOffsets",         m.UnitOffsetBuffer},
+        {"marchingCubeCases",   m.CasesBuffer},
+        {"triangleLayoutSizes", m.TriangleLayoutSizesBuffer},
+    }
+    for _,b := range bindings {
+        if err := program.BindStorageBuffer(b.name, b.buffer); err != nil {
+            return err
+        }
+    }
+
+    totalCubeCount := m.Grid.TotalCubeCount()
+    if err := program.SetFloats("isoLevels", m.Grid.IsoLevels); err != nil {
+        return err
+    }
+    if err := program.SetInt("totalCubeCount", int32(totalCubeCount)); err != nil {
+        return err
+    }
+    if err := program.SetFloat("cubeSize", m.Grid.CubeSize); err != nil {
+        return err
+    }
+
+    groupsX, groupsY, groupsZ := m.workGroups()
+
+    // This will fill the buffer with the sizes, that we need memory for in the next run.
+    if err := program.SetBool("calculateSizeOnly", true); err != nil {
+        return err
+    }
+    gl.DispatchCompute(groupsX, groupsY, groupsZ)
+
+    gl.MemoryBarrier(gl.BUFFER_UPDATE_BARRIER_BIT)
+
+    // The layout is ordered by iso-surface first. So after the prefix sum, every surface
+    // has its own continuous range of triangles.
+    layoutArraySize := len(m.layoutSizes)
+
+    // Add up all values, to determine the exact storage layout locations for each shader invocation
+    gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, m.TriangleLayoutSizesBuffer)
+    ptr := gl.MapBufferRange(gl.SHADER_STORAGE_BUFFER, 0, layoutArraySize*int(unsafe.Sizeof(int32(0))), gl.MAP_WRITE_BIT | gl.MAP_READ_BIT)
+    // This is really tricky. We get a pure C-Array pointer from glMapBufferRange, which is in our user address space.
+    // Unfortunately, we cannot use it directly in Go or convert it easily to a usable slice.
+    // So instead, we apply some magic (bit-shift-stuff) and work on int32 (same size as the GLSL int) directly. This should operate then directly
+    // on the underlaying C-Array in memory.
+    layoutSizes := (*[1 << 30]int32)(unsafe.Pointer(ptr))[:layoutArraySize:layoutArraySize]
+    var lastSize int = int(layoutSizes[layoutArraySize-1])
+    var sum  int32 = 0
+    var sum2 int32 = layoutSizes[0]
+    for i := 1; i < layoutArraySize; i++ {
+        sum = layoutSizes[i]
+        layoutSizes[i] = sum2
+        sum2 += sum
+    }
+    layoutSizes[0] = 0
+
+    // This determines, how many triangles actually have to be rendered!
+    m.TriangleCount = int(layoutSizes[layoutArraySize-1]) + lastSize
+    copy(m.layoutSizes, layoutSizes)
+
+    gl.UnmapBuffer(gl.SHADER_STORAGE_BUFFER)
+    gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, 0)
+
+    // Otherwise the second run would write behind the end of the buffer.
+    if m.TriangleCount > m.TriangleCapacity {
+        gl.DeleteBuffers(1, &m.PositionArrayBuffer)
+        gl.DeleteVertexArrays(1, &m.PositionVertexBuffer)
+        m.createPositionBuffers(m.TriangleCount + m.TriangleCount/4)
+    }
+
+    // This will actually create the triangle data seamless in the position buffer.
+    if err := program.SetBool("calculateSizeOnly", false); err != nil {
+        return err
+    }
+    if err := program.BindStorageBuffer("positionList", m.PositionArrayBuffer); err != nil {
+        return err
+    }
+
+    gl.DispatchCompute(groupsX, groupsY, groupsZ)
+
+    gl.MemoryBarrier(gl.BUFFER_UPDATE_BARRIER_BIT | gl.VERTEX_ATTRIB_ARRAY_BARRIER_BIT)
+    return nil
+}
+
+// The triangles of one block of cubes are [first, end) in the position buffer.
+func (m *MarchingCubesBuffers) triangleRange(firstCube, cubeCount int) (first, end int) {
+    first = int(m.layoutSizes[firstCube])
+    end = m.TriangleCount
+    if firstCube+cubeCount < len(m.layoutSizes) {
+        end = int(m.layoutSizes[firstCube+cubeCount])
+    }
+    return first, end
+}
+
+// The range of one unit of one iso-surface in the position buffer after the last run.
+func (m *MarchingCubesBuffers) UnitTriangles(unit, surface int) (first, end int) {
+    return m.triangleRange(m.Grid.CubeIndex(0, 0, 0, unit, surface), m.Grid.CubesPerUnit())
+}
+
+// The range of one iso-surface in the position buffer after the last run.
+func (m *MarchingCubesBuffers) SurfaceTriangles(surface int) (first, end int) {
+    return m.triangleRange(surface*m.Grid.TotalCubeCount(), m.Grid.TotalCubeCount())
+}
+
+// Reads back the cases, layout sizes and triangles (with normals) of the last run.
+func (m *MarchingCubesBuffers) ReadMesh() *Mesh {
+    cubeCount := len(m.layoutSizes)
+    mesh := &Mesh {
+        Grid:               m.Grid,
+        Cases:              make([]int32, cubeCount),
+        LayoutSizes:        make([]int32, cubeCount),
+        Triangles:          make([]MeshTriangle, m.TriangleCount),
+        Normals:            make([]MeshTriangle, m.TriangleCount),
+        MaterialIDs:        make([][3]float32, m.TriangleCount),
+        Colors:             make([][3]mgl32.Vec4, m.TriangleCount),
+        Occlusion:          make([][3]float32, m.TriangleCount),
+        UnitTriangleCounts: make([]int, len(m.Grid.UnitOffsets)),
+    }
+
+    ReadBuffer(m.CasesBuffer, cubeCount*int(unsafe.Sizeof(int32(0))), gl.Ptr(mesh.Cases))
+    ReadBuffer(m.TriangleLayoutSizesBuffer, cubeCount*int(unsafe.Sizeof(int32(0))), gl.Ptr(mesh.LayoutSizes))
+
+    if m.TriangleCount > 0 {
+        vertices := make([]Vertex, 3*m.TriangleCount)
+        ReadBuffer(m.PositionArrayBuffer, len(vertices)*int(unsafe.Sizeof(Vertex{})), gl.Ptr(vertices))
+        for i,_ := range mesh.Triangles {
+            for v := 0; v < 3; v++ {
+                vertex := vertices[3*i+v]
+                mesh.Triangles[i][v] = vertex.Pos.Vec3()
+                mesh.Normals[i][v] = vertex.Normal.Vec3()
+                mesh.MaterialIDs[i][v] = vertex.Pos[3]
+                mesh.Colors[i][v] = vertex.Color
+                mesh.Occlusion[i][v] = vertex.Normal[3]
+            }
+        }
+    }
+
+    for u,_ := range m.Grid.UnitOffsets {
+        for s,_ := range m.Grid.IsoLevels {
+            first, end := m.UnitTriangles(u, s)
+            mesh.UnitTriangleCounts[u] += end - first
+        }
+    }
+    return mesh
+}
+
+func (m *MarchingCubesBuffers) Delete() {
+    buffers := []uint32{m.PositionArrayBuffer, m.TriangleLayoutSizesBuffer, m.CasesBuffer, m.UnitOffsetBuffer, m.CaseToNumPolysBuffer, m.EdgeConnectListBuffer}
+    gl.DeleteBuffers(int32(len(buffers)), &buffers[0])
+    gl.DeleteVertexArrays(1, &m.PositionVertexBuffer)
+}
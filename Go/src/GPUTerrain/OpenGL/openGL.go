@@ -8,11 +8,18 @@ import (
     "bytes"
     "os"
     "io"
+    "io/fs"
+    "path/filepath"
+    "unsafe"
 )
 
 const (
 )
 
+// Where shaders (and their includes) are read from. nil reads them from the disk.
+// Programs from a file system are not watched for changes.
+var ShaderFiles fs.FS
+
 
 
 
@@ -34,8 +41,9 @@ func compileShader(source string, shaderType uint32) (uint32, error) {
 
         log := strings.Repeat("\x00", int(logLength+1))
         gl.GetShaderInfoLog(shader, logLength, nil, gl.Str(log))
+        gl.DeleteShader(shader)
 
-        return 0, fmt.Errorf("failed to compile %v: %v", source, log)
+        return 0, fmt.Errorf("failed to compile shader: %v", log)
     }
 
     return shader, nil
@@ -43,6 +51,11 @@ func compileShader(source string, shaderType uint32) (uint32, error) {
 
 func readFile(name string) (string, error) {
 
+    if ShaderFiles != nil {
+        source, err := fs.ReadFile(ShaderFiles, filepath.ToSlash(name))
+        return string(source), err
+    }
+
     buf := bytes.NewBuffer(nil)
     f, err := os.Open(name)
     if err != nil {
@@ -54,36 +67,21 @@ func readFile(name string) (string, error) {
     return string(buf.Bytes()), nil
 }
 
-// Mostly taken from the Demo. But compiling and linking shaders
-// just should be done like this anyways.
-func NewProgram(vertexShaderName, fragmentShaderName string) (uint32, error) {
-
-    vertexShaderSource, err := readFile(vertexShaderName)
-    if err != nil {
-        return 0, err
-    }
+// Links all given shaders into a new program. The shaders are deleted afterwards,
+// no matter if linking worked or not.
+func linkProgram(shaders ...uint32) (uint32, error) {
 
-    fragmentShaderSource, err := readFile(fragmentShaderName)
-    if err != nil {
-        return 0, err
-    }
+    program := gl.CreateProgram()
 
-    vertexShader, err := compileShader(vertexShaderSource, gl.VERTEX_SHADER)
-    if err != nil {
-        return 0, err
+    for _,shader := range shaders {
+        gl.AttachShader(program, shader)
     }
+    gl.LinkProgram(program)
 
-    fragmentShader, err := compileShader(fragmentShaderSource, gl.FRAGMENT_SHADER)
-    if err != nil {
-        return 0, err
+    for _,shader := range shaders {
+        gl.DeleteShader(shader)
     }
 
-    program := gl.CreateProgram()
-
-    gl.AttachShader(program, vertexShader)
-    gl.AttachShader(program, fragmentShader)
-    gl.LinkProgram(program)
-
     var status int32
     gl.GetProgramiv(program, gl.LINK_STATUS, &status)
     if status == gl.FALSE {
@@ -92,46 +90,94 @@ func NewProgram(vertexShaderName, fragmentShaderName string) (uint32, error) {
 
         log := strings.Repeat("\x00", int(logLength+1))
         gl.GetProgramInfoLog(program, logLength, nil, gl.Str(log))
+        gl.DeleteProgram(program)
 
         return 0, fmt.Errorf("failed to link program: %v", log)
     }
 
-    gl.DeleteShader(vertexShader)
-    gl.DeleteShader(fragmentShader)
-
     return program, nil
 }
 
-func NewComputeProgram(computeShaderName string) (uint32, error) {
+// Mostly taken from the Demo. But compiling and linking shaders
+// just should be done like this anyways.
+func NewProgram(vertexShaderName, fragmentShaderName string) (uint32, error) {
+    program, _, err := NewProgramWithDefines(vertexShaderName, fragmentShaderName, nil)
+    return program, err
+}
+
+// Both shaders are run through the preprocessor first.
+// Also returns all files the program depends on (including the #included ones).
+func NewProgramWithDefines(vertexShaderName, fragmentShaderName string, defines ShaderDefines) (uint32, []string, error) {
+
+    vertexShaderSource, vertexFiles, err := PreprocessShader(vertexShaderName, defines)
+    if err != nil {
+        return 0, vertexFiles, err
+    }
+
+    fragmentShaderSource, fragmentFiles, err := PreprocessShader(fragmentShaderName, defines)
+    files := append(vertexFiles, fragmentFiles...)
+    if err != nil {
+        return 0, files, err
+    }
 
-    computeShaderSource, err := readFile(computeShaderName)
+    vertexShader, err := compileShader(vertexShaderSource, gl.VERTEX_SHADER)
+    if err != nil {
+        return 0, files, annotateSourceNumbers(err, vertexFiles)
+    }
+
+    fragmentShader, err := compileShader(fragmentShaderSource, gl.FRAGMENT_SHADER)
+    if err != nil {
+        gl.DeleteShader(vertexShader)
+        return 0, files, annotateSourceNumbers(err, fragmentFiles)
+    }
+
+    program, err := linkProgram(vertexShader, fragmentShader)
+    return program, files, err
+}
+
+func NewProgramFromSource(vertexShaderSource, fragmentShaderSource string) (uint32, error) {
+
+    vertexShader, err := compileShader(vertexShaderSource, gl.VERTEX_SHADER)
     if err != nil {
         return 0, err
     }
-    computeShader, err := compileShader(computeShaderSource, gl.COMPUTE_SHADER)
+
+    fragmentShader, err := compileShader(fragmentShaderSource, gl.FRAGMENT_SHADER)
     if err != nil {
+        gl.DeleteShader(vertexShader)
         return 0, err
     }
-    program := gl.CreateProgram()
 
-    gl.AttachShader(program, computeShader)
-    gl.LinkProgram(program)
+    return linkProgram(vertexShader, fragmentShader)
+}
 
-    var status int32
-    gl.GetProgramiv(program, gl.LINK_STATUS, &status)
-    if status == gl.FALSE {
-        var logLength int32
-        gl.GetProgramiv(program, gl.INFO_LOG_LENGTH, &logLength)
+func NewComputeProgram(computeShaderName string) (uint32, error) {
+    program, _, err := NewComputeProgramWithDefines(computeShaderName, nil)
+    return program, err
+}
 
-        log := strings.Repeat("\x00", int(logLength+1))
-        gl.GetProgramInfoLog(program, logLength, nil, gl.Str(log))
+func NewComputeProgramWithDefines(computeShaderName string, defines ShaderDefines) (uint32, []string, error) {
 
-        return 0, fmt.Errorf("failed to link program: %v", log)
+    computeShaderSource, files, err := PreprocessShader(computeShaderName, defines)
+    if err != nil {
+        return 0, files, err
     }
 
-    gl.DeleteShader(computeShader)
+    program, err := NewComputeProgramFromSource(computeShaderSource)
+    if err != nil {
+        return 0, files, annotateSourceNumbers(err, files)
+    }
+    return program, files, nil
+}
 
-    return program, nil
+func NewComputeProgramFromSource(computeShaderSource string) (uint32, error) {
+
+    computeShader, err := compileShader(computeShaderSource, gl.COMPUTE_SHADER)
+    if err != nil {
+        return 0, err
+    }
+
+    return linkProgram(computeShader)
 }
 
 func CreateMSTexture(tex *uint32, width, height, internalFormat int32, format, internalType uint32) {
@@ -196,12 +242,18 @@ func CreateLightFbo(fbo, colorTex, depthTex *uint32, width, height int32, multis
 }
 
 func CreateFbo(fbo, colorTex, depthTex *uint32, width, height int32, multisampling bool) {
+    CreateFboWithFormat(fbo, colorTex, depthTex, width, height, gl.RGBA8, multisampling)
+}
 
+// colorFormat is the internal format of the colour texture, i.e. gl.RGBA16F for HDR rendering.
+func CreateFboWithFormat(fbo, colorTex, depthTex *uint32, width, height int32, colorFormat int32, multisampling bool) {
+
+    // No data is uploaded, so the type only has to be valid for the format.
     if colorTex != nil {
         if multisampling {
-            CreateMSTexture(colorTex, width, height, gl.RGBA8, gl.RGBA, gl.UNSIGNED_BYTE)
+            CreateMSTexture(colorTex, width, height, colorFormat, gl.RGBA, gl.FLOAT)
         } else {
-            CreateTexture(colorTex, width, height, gl.RGBA8, gl.RGBA, gl.UNSIGNED_BYTE)
+            CreateTexture(colorTex, width, height, colorFormat, gl.RGBA, gl.FLOAT)
         }
     }
     if depthTex != nil {
@@ -221,6 +273,42 @@ func CreateFbo(fbo, colorTex, depthTex *uint32, width, height int32, multisampli
 
 }
 
+// Deletes the FBO and its textures (nil for none) and sets all of them to 0.
+func DeleteFbo(fbo, colorTex, depthTex *uint32) {
+    for _,tex := range []*uint32{colorTex, depthTex} {
+        if tex != nil {
+            gl.DeleteTextures(1, tex)
+            *tex = 0
+        }
+    }
+    gl.DeleteFramebuffers(1, fbo)
+    *fbo = 0
+}
+
 
 
 
+// One RGBA8 layer per entry of layers. All layers need width*height*4 bytes.
+// Repeats and is mipmapped, because it is used to texture large surfaces.
+func CreateTextureArray(tex *uint32, width, height int32, layers [][]uint8) {
+    gl.GenTextures(1, tex);
+    gl.BindTexture(gl.TEXTURE_2D_ARRAY, *tex);
+    gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_WRAP_S, gl.REPEAT);
+    gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_WRAP_T, gl.REPEAT);
+    gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR);
+    gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_MAG_FILTER, gl.LINEAR);
+    gl.TexImage3D(gl.TEXTURE_2D_ARRAY, 0, gl.RGBA8, width, height, int32(len(layers)), 0, gl.RGBA, gl.UNSIGNED_BYTE, nil);
+    for i,layer := range layers {
+        gl.TexSubImage3D(gl.TEXTURE_2D_ARRAY, 0, 0, 0, int32(i), width, height, 1, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(layer));
+    }
+    gl.GenerateMipmap(gl.TEXTURE_2D_ARRAY);
+    gl.BindTexture(gl.TEXTURE_2D_ARRAY, 0);
+}
+
+
+// Copies the first size bytes of the buffer into data. Used to read back, what a compute shader wrote.
+func ReadBuffer(buffer uint32, size int, data unsafe.Pointer) {
+    gl.BindBuffer(gl.COPY_READ_BUFFER, buffer);
+    gl.GetBufferSubData(gl.COPY_READ_BUFFER, 0, size, data);
+    gl.BindBuffer(gl.COPY_READ_BUFFER, 0);
+}
@@ -0,0 +1,146 @@
+package opengl
+
+import (
+    "github.com/go-gl/mathgl/mgl32"
+    "fmt"
+)
+
+type ParameterType int
+
+const (
+    FloatParameter ParameterType = iota
+    Vec3Parameter
+)
+
+// A named value, that is uploaded as uniform with the same name.
+// Floats only use the first component of Value.
+type Parameter struct {
+    Name    string
+    Type    ParameterType
+    Value   mgl32.Vec3
+    // How much the value changes per key press when tweaked live.
+    Step    float32
+}
+
+// Number of floats that can be changed independently.
+func (p *Parameter) Components() int {
+    if p.Type == Vec3Parameter {
+        return 3
+    }
+    return 1
+}
+
+func (p *Parameter) String() string {
+    if p.Type == Vec3Parameter {
+        return fmt.Sprintf("%v = (%.3f, %.3f, %.3f)", p.Name, p.Value[0], p.Value[1], p.Value[2])
+    }
+    return fmt.Sprintf("%v = %.3f", p.Name, p.Value[0])
+}
+
+// All parameters, a shader (i.e. the density function) depends on.
+// Every change is reported to OnChange, so dependent data can be marked for recalculation.
+type ParameterRegistry struct {
+    parameters  []*Parameter
+    byName      map[string]*Parameter
+    OnChange    func(p *Parameter)
+}
+
+func NewParameterRegistry() *ParameterRegistry {
+    return &ParameterRegistry {
+        byName: make(map[string]*Parameter),
+    }
+}
+
+func (r *ParameterRegistry) declare(name string, t ParameterType, value mgl32.Vec3, step float32) *Parameter {
+    if _, ok := r.byName[name]; ok {
+        panic(fmt.Sprintf("parameter %v is declared twice", name))
+    }
+    p := &Parameter{Name: name, Type: t, Value: value, Step: step}
+    r.parameters = append(r.parameters, p)
+    r.byName[name] = p
+    return p
+}
+
+func (r *ParameterRegistry) DeclareFloat(name string, value, step float32) *Parameter {
+    return r.declare(name, FloatParameter, mgl32.Vec3{value,0,0}, step)
+}
+
+func (r *ParameterRegistry) DeclareVec3(name string, value mgl32.Vec3, step float32) *Parameter {
+    return r.declare(name, Vec3Parameter, value, step)
+}
+
+// In declaration order.
+func (r *ParameterRegistry) Parameters() []*Parameter {
+    return r.parameters
+}
+
+func (r *ParameterRegistry) Get(name string) (*Parameter, error) {
+    p, ok := r.byName[name]
+    if !ok {
+        return nil, fmt.Errorf("parameter %v does not exist", name)
+    }
+    return p, nil
+}
+
+func (r *ParameterRegistry) set(p *Parameter, value mgl32.Vec3) {
+    if p.Value == value {
+        return
+    }
+    p.Value = value
+    if r.OnChange != nil {
+        r.OnChange(p)
+    }
+}
+
+func (r *ParameterRegistry) SetFloat(name string, value float32) error {
+    p, err := r.Get(name)
+    if err != nil {
+        return err
+    }
+    if p.Type != FloatParameter {
+        return fmt.Errorf("parameter %v is not a float", name)
+    }
+    r.set(p, mgl32.Vec3{value,0,0})
+    return nil
+}
+
+func (r *ParameterRegistry) SetVec3(name string, value mgl32.Vec3) error {
+    p, err := r.Get(name)
+    if err != nil {
+        return err
+    }
+    if p.Type != Vec3Parameter {
+        return fmt.Errorf("parameter %v is not a vec3", name)
+    }
+    r.set(p, value)
+    return nil
+}
+
+// Changes one component of the parameter by steps*Step. Used for live tweaking.
+func (r *ParameterRegistry) Nudge(p *Parameter, component int, steps float32) {
+    value := p.Value
+    value[component] += steps*p.Step
+    r.set(p, value)
+}
+
+// Uploads all parameters as uniforms of the same name.
+// Parameters the shader does not use (or that got optimized away) are skipped.
+// A parameter with a different type in the shader is an error.
+func (r *ParameterRegistry) Upload(program *Program) error {
+    for _,p := range r.parameters {
+        if !program.HasUniform(p.Name) {
+            continue
+        }
+        var err error
+        switch p.Type {
+            case FloatParameter:
+                err = program.SetFloat(p.Name, p.Value[0])
+            case Vec3Parameter:
+                err = program.SetVec3(p.Name, p.Value)
+        }
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
@@ -0,0 +1,138 @@
+package opengl
+
+import (
+    "fmt"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// Values that are injected as #define right after the #version line of a shader.
+// Floats always get a decimal point, so GLSL does not treat them as int. Bools become 1 or 0,
+// so they can be used with #if.
+type ShaderDefines map[string]interface{}
+
+var includePattern = regexp.MustCompile(`^\s*#\s*include\s+"([^"]+)"`)
+var versionPattern = regexp.MustCompile(`^\s*#\s*version\s`)
+
+func formatDefine(value interface{}) string {
+    switch v := value.(type) {
+        case bool:
+            if v {
+                return "1"
+            }
+            return "0"
+        case float32:
+            return formatFloat(float64(v), 32)
+        case float64:
+            return formatFloat(v, 64)
+        default:
+            return fmt.Sprint(v)
+    }
+}
+
+func formatFloat(f float64, bitSize int) string {
+    s := strconv.FormatFloat(f, 'g', -1, bitSize)
+    if !strings.ContainsAny(s, ".eEn") {
+        s += ".0"
+    }
+    return s
+}
+
+type preprocessor struct {
+    // Every file gets its own source string number for the #line directives.
+    // So an error in 2:15 is in line 15 of files[2].
+    files       []string
+    // Files are only included once.
+    included    map[string]bool
+    // Detects include cycles.
+    stack       []string
+}
+
+// Resolves all #include "file" directives (relative to the including file) recursively and
+// injects the defines after the #version line of the main file.
+// Returns the final source and all files that were read, in source string number order.
+func PreprocessShader(fileName string, defines ShaderDefines) (string, []string, error) {
+    p := &preprocessor {
+        included: make(map[string]bool),
+    }
+
+    var out strings.Builder
+    if err := p.process(filepath.Clean(fileName), defines, &out); err != nil {
+        return "", p.files, err
+    }
+    return out.String(), p.files, nil
+}
+
+func (p *preprocessor) process(fileName string, defines ShaderDefines, out *strings.Builder) error {
+
+    for _,f := range p.stack {
+        if f == fileName {
+            return fmt.Errorf("include cycle: %v -> %v", strings.Join(p.stack, " -> "), fileName)
+        }
+    }
+    if p.included[fileName] {
+        return nil
+    }
+
+    source, err := readFile(fileName)
+    if err != nil {
+        return err
+    }
+
+    p.included[fileName] = true
+    p.stack = append(p.stack, fileName)
+    defer func() { p.stack = p.stack[:len(p.stack)-1] }()
+
+    fileIndex := len(p.files)
+    p.files = append(p.files, fileName)
+
+    // Includes are inlined. The main file must not start with a #line directive,
+    // because #version has to come first.
+    if fileIndex != 0 {
+        fmt.Fprintf(out, "#line 1 %v\n", fileIndex)
+    }
+
+    lines := strings.Split(source, "\n")
+    for i,line := range lines {
+        lineNumber := i+1
+
+        if match := includePattern.FindStringSubmatch(line); match != nil {
+            includeName := filepath.Join(filepath.Dir(fileName), match[1])
+            if err := p.process(includeName, nil, out); err != nil {
+                return fmt.Errorf("%v:%v: %v", fileName, lineNumber, err)
+            }
+            fmt.Fprintf(out, "#line %v %v\n", lineNumber+1, fileIndex)
+            continue
+        }
+
+        out.WriteString(line)
+        out.WriteString("\n")
+
+        if fileIndex == 0 && versionPattern.MatchString(line) && len(defines) > 0 {
+            // Sorted, so the same defines always result in the same source.
+            names := make([]string, 0, len(defines))
+            for name,_ := range defines {
+                names = append(names, name)
+            }
+            sort.Strings(names)
+            for _,name := range names {
+                fmt.Fprintf(out, "#define %v %v\n", name, formatDefine(defines[name]))
+            }
+            fmt.Fprintf(out, "#line %v %v\n", lineNumber+1, fileIndex)
+        }
+    }
+
+    return nil
+}
+
+// Appends which source string number belongs to which file. OpenGL only reports the numbers.
+func annotateSourceNumbers(err error, files []string) error {
+    legend := make([]string, len(files))
+    for i,f := range files {
+        legend[i] = fmt.Sprintf("  %v: %v", i, f)
+    }
+    return fmt.Errorf("%v\nsource string numbers:\n%v", err, strings.Join(legend, "\n"))
+}
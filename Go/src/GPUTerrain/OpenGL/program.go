@@ -0,0 +1,255 @@
+package opengl
+
+import (
+    "github.com/go-gl/gl/v4.5-core/gl"
+    "github.com/go-gl/mathgl/mgl32"
+    "fmt"
+    "strings"
+)
+
+type Uniform struct {
+    Location    int32
+    // The GLSL type, like gl.FLOAT_VEC3.
+    Type        uint32
+    // Number of elements. 1 for non-arrays.
+    Size        int32
+}
+
+type StorageBlock struct {
+    Binding     uint32
+}
+
+// A linked program with all active uniforms and shader storage blocks looked up once after linking.
+//
+// Uniforms that the compiler optimized away are not active, so setting them is an error as well.
+// The setters use glProgramUniform, so the program does not have to be in use.
+type Program struct {
+    ID              uint32
+    uniforms        map[string]Uniform
+    storageBlocks   map[string]StorageBlock
+}
+
+// Looks up all active uniforms and shader storage blocks of a linked program.
+func IntrospectProgram(id uint32) *Program {
+    p := &Program {
+        ID:            id,
+        uniforms:      make(map[string]Uniform),
+        storageBlocks: make(map[string]StorageBlock),
+    }
+
+    uniformProperties := []uint32{gl.LOCATION, gl.TYPE, gl.ARRAY_SIZE, gl.BLOCK_INDEX}
+    for i,name := range resourceNames(id, gl.UNIFORM) {
+        values := make([]int32, len(uniformProperties))
+        gl.GetProgramResourceiv(id, gl.UNIFORM, uint32(i), int32(len(uniformProperties)), &uniformProperties[0], int32(len(values)), nil, &values[0])
+        // Members of uniform blocks have no location.
+        if values[3] != -1 {
+            continue
+        }
+        // Arrays are reported as "name[0]". They are set by their plain name.
+        name = strings.TrimSuffix(name, "[0]")
+        p.uniforms[name] = Uniform{Location: values[0], Type: uint32(values[1]), Size: values[2]}
+    }
+
+    blockProperties := []uint32{gl.BUFFER_BINDING}
+    for i,name := range resourceNames(id, gl.SHADER_STORAGE_BLOCK) {
+        var binding int32
+        gl.GetProgramResourceiv(id, gl.SHADER_STORAGE_BLOCK, uint32(i), 1, &blockProperties[0], 1, nil, &binding)
+        p.storageBlocks[name] = StorageBlock{Binding: uint32(binding)}
+    }
+
+    return p
+}
+
+// The names of all active resources of an interface, ordered by resource index.
+func resourceNames(program uint32, programInterface uint32) []string {
+    var count, maxLength int32
+    gl.GetProgramInterfaceiv(program, programInterface, gl.ACTIVE_RESOURCES, &count)
+    gl.GetProgramInterfaceiv(program, programInterface, gl.MAX_NAME_LENGTH, &maxLength)
+
+    names := make([]string, count)
+    if count == 0 {
+        return names
+    }
+    buffer := make([]uint8, maxLength+1)
+    for i := range names {
+        gl.GetProgramResourceName(program, programInterface, uint32(i), int32(len(buffer)), nil, &buffer[0])
+        names[i] = gl.GoStr(&buffer[0])
+    }
+    return names
+}
+
+func typeName(t uint32) string {
+    switch t {
+        case gl.FLOAT:          return "float"
+        case gl.FLOAT_VEC2:     return "vec2"
+        case gl.FLOAT_VEC3:     return "vec3"
+        case gl.FLOAT_VEC4:     return "vec4"
+        case gl.INT:            return "int"
+        case gl.UNSIGNED_INT:   return "uint"
+        case gl.BOOL:           return "bool"
+        case gl.FLOAT_MAT4:     return "mat4"
+        case gl.SAMPLER_2D:     return "sampler2D"
+        case gl.SAMPLER_2D_ARRAY: return "sampler2DArray"
+    }
+    return fmt.Sprintf("type 0x%x", t)
+}
+
+func (p *Program) HasUniform(name string) bool {
+    _, ok := p.uniforms[name]
+    return ok
+}
+
+func (p *Program) Uniforms() map[string]Uniform {
+    return p.uniforms
+}
+
+// Returns the uniform, if it is active and has one of the given types.
+func (p *Program) uniform(name string, types ...uint32) (Uniform, error) {
+    u, ok := p.uniforms[name]
+    if !ok {
+        return u, fmt.Errorf("program %v: no active uniform %q", p.ID, name)
+    }
+    for _,t := range types {
+        if u.Type == t {
+            return u, nil
+        }
+    }
+    return u, fmt.Errorf("program %v: uniform %q has type %v, not %v", p.ID, name, typeName(u.Type), typeName(types[0]))
+}
+
+func (p *Program) checkArraySize(name string, u Uniform, count int) error {
+    if count > int(u.Size) {
+        return fmt.Errorf("program %v: uniform %q has %v elements, got %v values", p.ID, name, u.Size, count)
+    }
+    return nil
+}
+
+// Also used for samplers, which are set by their texture unit.
+func (p *Program) SetInt(name string, value int32) error {
+    u, err := p.uniform(name, gl.INT, gl.BOOL, gl.SAMPLER_2D, gl.SAMPLER_2D_ARRAY, gl.SAMPLER_2D_SHADOW, gl.SAMPLER_2D_ARRAY_SHADOW)
+    if err != nil {
+        return err
+    }
+    gl.ProgramUniform1i(p.ID, u.Location, value)
+    return nil
+}
+
+// Sets the first len(values) elements of an int or sampler array.
+func (p *Program) SetInts(name string, values []int32) error {
+    u, err := p.uniform(name, gl.INT, gl.SAMPLER_2D, gl.SAMPLER_2D_ARRAY, gl.SAMPLER_2D_SHADOW, gl.SAMPLER_2D_ARRAY_SHADOW)
+    if err != nil {
+        return err
+    }
+    if err := p.checkArraySize(name, u, len(values)); err != nil || len(values) == 0 {
+        return err
+    }
+    gl.ProgramUniform1iv(p.ID, u.Location, int32(len(values)), &values[0])
+    return nil
+}
+
+func (p *Program) SetBool(name string, value bool) error {
+    u, err := p.uniform(name, gl.BOOL)
+    if err != nil {
+        return err
+    }
+    var v int32 = 0
+    if value {
+        v = 1
+    }
+    gl.ProgramUniform1i(p.ID, u.Location, v)
+    return nil
+}
+
+func (p *Program) SetFloat(name string, value float32) error {
+    u, err := p.uniform(name, gl.FLOAT)
+    if err != nil {
+        return err
+    }
+    gl.ProgramUniform1f(p.ID, u.Location, value)
+    return nil
+}
+
+// Sets the first len(values) elements of a float array.
+func (p *Program) SetFloats(name string, values []float32) error {
+    u, err := p.uniform(name, gl.FLOAT)
+    if err != nil {
+        return err
+    }
+    if err := p.checkArraySize(name, u, len(values)); err != nil || len(values) == 0 {
+        return err
+    }
+    gl.ProgramUniform1fv(p.ID, u.Location, int32(len(values)), &values[0])
+    return nil
+}
+
+func (p *Program) SetVec3(name string, value mgl32.Vec3) error {
+    u, err := p.uniform(name, gl.FLOAT_VEC3)
+    if err != nil {
+        return err
+    }
+    gl.ProgramUniform3fv(p.ID, u.Location, 1, &value[0])
+    return nil
+}
+
+// Sets the first len(values) elements of a vec3 array.
+func (p *Program) SetVec3s(name string, values []mgl32.Vec3) error {
+    u, err := p.uniform(name, gl.FLOAT_VEC3)
+    if err != nil {
+        return err
+    }
+    if err := p.checkArraySize(name, u, len(values)); err != nil || len(values) == 0 {
+        return err
+    }
+    gl.ProgramUniform3fv(p.ID, u.Location, int32(len(values)), &values[0][0])
+    return nil
+}
+
+func (p *Program) SetVec4(name string, value mgl32.Vec4) error {
+    u, err := p.uniform(name, gl.FLOAT_VEC4)
+    if err != nil {
+        return err
+    }
+    gl.ProgramUniform4fv(p.ID, u.Location, 1, &value[0])
+    return nil
+}
+
+func (p *Program) SetMat4(name string, value mgl32.Mat4) error {
+    u, err := p.uniform(name, gl.FLOAT_MAT4)
+    if err != nil {
+        return err
+    }
+    gl.ProgramUniformMatrix4fv(p.ID, u.Location, 1, false, &value[0])
+    return nil
+}
+
+// Sets the first len(values) elements of a mat4 array.
+func (p *Program) SetMat4s(name string, values []mgl32.Mat4) error {
+    u, err := p.uniform(name, gl.FLOAT_MAT4)
+    if err != nil {
+        return err
+    }
+    if err := p.checkArraySize(name, u, len(values)); err != nil || len(values) == 0 {
+        return err
+    }
+    gl.ProgramUniformMatrix4fv(p.ID, u.Location, int32(len(values)), false, &values[0][0])
+    return nil
+}
+
+// The binding point, the shader declared for the storage block.
+func (p *Program) StorageBlockBinding(name string) (uint32, error) {
+    b, ok := p.storageBlocks[name]
+    if !ok {
+        return 0, fmt.Errorf("program %v: no active shader storage block %q", p.ID, name)
+    }
+    return b.Binding, nil
+}
+
+// Binds the buffer to the binding point of the storage block. So the binding numbers only live in the shader.
+func (p *Program) BindStorageBuffer(name string, buffer uint32) error {
+    binding, err := p.StorageBlockBinding(name)
+    if err != nil {
+        return err
+    }
+    gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, binding, buffer)
+    return nil
+}
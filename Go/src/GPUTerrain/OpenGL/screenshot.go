@@ -0,0 +1,51 @@
+package opengl
+
+import (
+    "github.com/go-gl/gl/v4.5-core/gl"
+    "image"
+    "image/png"
+    "os"
+)
+
+// Reads the colour attachment of the FBO back. 0 is the default framebuffer (the back buffer before swapping).
+// OpenGL starts at the bottom row, images at the top row. So the rows are flipped.
+func ReadFramebuffer(fbo uint32, width, height int32) *image.RGBA {
+    img := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+    pixels := make([]uint8, len(img.Pix))
+
+    gl.BindFramebuffer(gl.READ_FRAMEBUFFER, fbo)
+    gl.PixelStorei(gl.PACK_ALIGNMENT, 1)
+    gl.ReadPixels(0, 0, width, height, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(pixels))
+    gl.BindFramebuffer(gl.READ_FRAMEBUFFER, 0)
+
+    rowSize := int(width)*4
+    for y := 0; y < int(height); y++ {
+        copy(img.Pix[y*rowSize:(y+1)*rowSize], pixels[(int(height)-1-y)*rowSize:(int(height)-y)*rowSize])
+    }
+    // The alpha channel is whatever the transparent surfaces left in there. Not meant for compositing.
+    for i := 3; i < len(img.Pix); i += 4 {
+        img.Pix[i] = 255
+    }
+    return img
+}
+
+func SavePNG(fileName string, img image.Image) error {
+    file, err := os.Create(fileName)
+    if err != nil {
+        return err
+    }
+    if err := png.Encode(file, img); err != nil {
+        file.Close()
+        return err
+    }
+    return file.Close()
+}
+
+func LoadPNG(fileName string) (image.Image, error) {
+    file, err := os.Open(fileName)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+    return png.Decode(file)
+}
@@ -0,0 +1,168 @@
+package opengl
+
+import (
+    "github.com/go-gl/gl/v4.5-core/gl"
+    "github.com/go-gl/mathgl/mgl32"
+    "math"
+)
+
+type ShadowFilter int
+
+const (
+    // Percentage closer filtering on the depth texture.
+    PCFShadowFilter ShadowFilter = iota
+    // Variance shadow maps on the (depth, depth²) moments in the RG32F colour texture.
+    VSMShadowFilter
+)
+
+func (f ShadowFilter) String() string {
+    if f == VSMShadowFilter {
+        return "VSM"
+    }
+    return "PCF"
+}
+
+// One shadow map, covering one depth range of the camera frustum.
+type ShadowCascade struct {
+    Fbo                 uint32
+    // (depth, depth²) for VSM.
+    MomentsTex          uint32
+    // Depth for PCF.
+    DepthTex            uint32
+    // View space distance, up to which this cascade is used.
+    Far                 float32
+    LightViewProjection mgl32.Mat4
+}
+
+// The camera frustum is split into several cascades up to Distance. Close cascades cover a small area
+// with the same resolution, so shadows stay sharp near the camera and still reach far into the world.
+type CascadedShadowMaps struct {
+    Size        int32
+    // Nothing is shadowed beyond this distance from the camera.
+    Distance    float32
+    // Blends between uniform (0) and logarithmic (1) split distances.
+    Lambda      float32
+    // Shadow casters up to this distance outside of a cascade (towards the light) are still rendered.
+    CasterMargin float32
+    Cascades    []ShadowCascade
+}
+
+func NewCascadedShadowMaps(cascadeCount int, size int32, distance float32) *CascadedShadowMaps {
+    s := &CascadedShadowMaps {
+        Size:         size,
+        Distance:     distance,
+        Lambda:       0.75,
+        CasterMargin: 100.0,
+        Cascades:     make([]ShadowCascade, cascadeCount),
+    }
+
+    for i,_ := range s.Cascades {
+        c := &s.Cascades[i]
+        CreateLightFbo(&c.Fbo, &c.MomentsTex, &c.DepthTex, size, size, false)
+
+        // The moments are filtered. That is the whole point of VSM.
+        gl.BindTexture(gl.TEXTURE_2D, c.MomentsTex)
+        gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+        gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+    }
+    gl.BindTexture(gl.TEXTURE_2D, 0)
+
+    return s
+}
+
+// Split distances after "Parallel-Split Shadow Maps" (Zhang et al.).
+func (s *CascadedShadowMaps) splitDistance(i int, near float32) float32 {
+    p := float32(i) / float32(len(s.Cascades))
+    logarithmic := near * float32(math.Pow(float64(s.Distance/near), float64(p)))
+    uniform     := near + (s.Distance-near)*p
+    return s.Lambda*logarithmic + (1.0-s.Lambda)*uniform
+}
+
+// Fits every cascade around its part of the camera frustum, as seen from a directional light.
+// lightDirection points towards the light.
+func (s *CascadedShadowMaps) Fit(view mgl32.Mat4, fovy, aspect, near float32, lightDirection mgl32.Vec3) {
+    lightDirection = lightDirection.Normalize()
+    up := mgl32.Vec3{0,1,0}
+    if math.Abs(float64(lightDirection.Dot(up))) > 0.99 {
+        up = mgl32.Vec3{1,0,0}
+    }
+
+    for i,_ := range s.Cascades {
+        c := &s.Cascades[i]
+        cascadeNear := s.splitDistance(i, near)
+        c.Far = s.splitDistance(i+1, near)
+
+        inverse := mgl32.Perspective(fovy, aspect, cascadeNear, c.Far).Mul4(view).Inv()
+
+        var corners [8]mgl32.Vec3
+        center := mgl32.Vec3{}
+        for j,_ := range corners {
+            ndc := mgl32.Vec4{float32(j&1)*2-1, float32((j>>1)&1)*2-1, float32((j>>2)&1)*2-1, 1}
+            corners[j] = mgl32.TransformCoordinate(ndc.Vec3(), inverse)
+            center = center.Add(corners[j])
+        }
+        center = center.Mul(1.0/8.0)
+
+        // A bounding sphere instead of a tight box. So the size of the cascade does not change,
+        // when the camera rotates, which would make the shadow edges flicker.
+        var radius float32 = 0.0
+        for _,corner := range corners {
+            radius = float32(math.Max(float64(radius), float64(corner.Sub(center).Len())))
+        }
+        radius = float32(math.Ceil(float64(radius)))
+
+        eye := center.Add(lightDirection.Mul(radius + s.CasterMargin))
+        lightView := mgl32.LookAtV(eye, center, up)
+        lightProjection := mgl32.Ortho(-radius, radius, -radius, radius, 0.0, 2.0*radius + s.CasterMargin)
+        viewProjection := lightProjection.Mul4(lightView)
+
+        // Moves the cascade in whole texels only. Otherwise the shadow edges swim, when the camera moves.
+        origin := viewProjection.Mul4x1(mgl32.Vec4{0,0,0,1}).Mul(float32(s.Size)/2.0)
+        offsetX := (float32(math.Round(float64(origin.X()))) - origin.X()) * 2.0 / float32(s.Size)
+        offsetY := (float32(math.Round(float64(origin.Y()))) - origin.Y()) * 2.0 / float32(s.Size)
+        c.LightViewProjection = mgl32.Translate3D(offsetX, offsetY, 0).Mul4(viewProjection)
+    }
+}
+
+// Binds the FBO of the cascade and clears it. Everything rendered afterwards casts a shadow.
+func (s *CascadedShadowMaps) BeginCascade(i int) {
+    gl.BindFramebuffer(gl.FRAMEBUFFER, s.Cascades[i].Fbo)
+    gl.Viewport(0, 0, s.Size, s.Size)
+    // Maximum depth (and its square) for VSM, so empty texels are lit.
+    gl.ClearColor(1, 1, 0, 0)
+    gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+}
+
+func (s *CascadedShadowMaps) LightViewProjections() []mgl32.Mat4 {
+    matrices := make([]mgl32.Mat4, len(s.Cascades))
+    for i,c := range s.Cascades {
+        matrices[i] = c.LightViewProjection
+    }
+    return matrices
+}
+
+func (s *CascadedShadowMaps) Splits() []float32 {
+    splits := make([]float32, len(s.Cascades))
+    for i,c := range s.Cascades {
+        splits[i] = c.Far
+    }
+    return splits
+}
+
+// Binds the depth textures of all cascades to consecutive texture units, starting at firstUnit,
+// followed by all moment textures. Returns the texture units of both.
+func (s *CascadedShadowMaps) BindTextures(firstUnit int32) ([]int32, []int32) {
+    depthUnits   := make([]int32, len(s.Cascades))
+    momentsUnits := make([]int32, len(s.Cascades))
+    for i,c := range s.Cascades {
+        depthUnits[i]   = firstUnit + int32(i)
+        momentsUnits[i] = firstUnit + int32(len(s.Cascades) + i)
+
+        gl.ActiveTexture(gl.TEXTURE0 + uint32(depthUnits[i]))
+        gl.BindTexture(gl.TEXTURE_2D, c.DepthTex)
+        gl.ActiveTexture(gl.TEXTURE0 + uint32(momentsUnits[i]))
+        gl.BindTexture(gl.TEXTURE_2D, c.MomentsTex)
+    }
+    gl.ActiveTexture(gl.TEXTURE0)
+    return depthUnits, momentsUnits
+}
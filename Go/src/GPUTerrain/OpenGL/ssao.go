@@ -0,0 +1,82 @@
+package opengl
+
+import (
+    "github.com/go-gl/gl/v4.5-core/gl"
+    "github.com/go-gl/mathgl/mgl32"
+    "math/rand"
+)
+
+// Render targets and settings of screen space ambient occlusion.
+//
+// The occluders are rendered into GeometryFbo (view space normals and depth). The occlusion is
+// calculated into AoTex and blurred into BlurTex, which is then used by the lighting.
+type SSAO struct {
+    Width, Height   int32
+    GeometryFbo     uint32
+    // View space normals, mapped to [0,1].
+    NormalTex       uint32
+    DepthTex        uint32
+    AoFbo           uint32
+    AoTex           uint32
+    BlurFbo         uint32
+    BlurTex         uint32
+    // View space distance, in which geometry occludes.
+    Radius          float32
+    // Hemisphere around the normal. Denser towards the center.
+    Kernel          []mgl32.Vec3
+}
+
+func NewSSAO(width, height int32, sampleCount int, radius float32) *SSAO {
+    s := &SSAO {
+        Radius: radius,
+    }
+    s.createRenderTargets(width, height)
+    s.SetSampleCount(sampleCount)
+    return s
+}
+
+func (s *SSAO) createRenderTargets(width, height int32) {
+    s.Width, s.Height = width, height
+
+    CreateFbo(&s.GeometryFbo, &s.NormalTex, &s.DepthTex, width, height, false)
+
+    CreateTexture(&s.AoTex, width, height, gl.R8, gl.RED, gl.UNSIGNED_BYTE)
+    CreateFboWithExistingTextures(&s.AoFbo, &s.AoTex, nil, gl.TEXTURE_2D)
+
+    CreateTexture(&s.BlurTex, width, height, gl.R8, gl.RED, gl.UNSIGNED_BYTE)
+    CreateFboWithExistingTextures(&s.BlurFbo, &s.BlurTex, nil, gl.TEXTURE_2D)
+}
+
+// Recreates all render targets with the new screen size. The kernel stays the same.
+func (s *SSAO) Resize(width, height int32) {
+    DeleteFbo(&s.GeometryFbo, &s.NormalTex, &s.DepthTex)
+    DeleteFbo(&s.AoFbo, &s.AoTex, nil)
+    DeleteFbo(&s.BlurFbo, &s.BlurTex, nil)
+    s.createRenderTargets(width, height)
+}
+
+// Creates a new kernel. Always the same for the same count.
+func (s *SSAO) SetSampleCount(count int) {
+    random := rand.New(rand.NewSource(1))
+
+    s.Kernel = make([]mgl32.Vec3, count)
+    for i,_ := range s.Kernel {
+        sample := mgl32.Vec3{random.Float32()*2-1, random.Float32()*2-1, random.Float32()}.Normalize()
+        // More samples close to the center, where occlusion matters most.
+        scale := float32(i) / float32(count)
+        scale = 0.1 + 0.9*scale*scale
+        s.Kernel[i] = sample.Mul(random.Float32() * scale)
+    }
+}
+
+func (s *SSAO) SampleCount() int {
+    return len(s.Kernel)
+}
+
+// Binds the FBO for the occluders and clears it.
+func (s *SSAO) BeginGeometry() {
+    gl.BindFramebuffer(gl.FRAMEBUFFER, s.GeometryFbo)
+    gl.Viewport(0, 0, s.Width, s.Height)
+    gl.ClearColor(0.5, 0.5, 1.0, 1.0)
+    gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+}
@@ -0,0 +1,149 @@
+package opengl
+
+import (
+    "github.com/go-gl/gl/v4.5-core/gl"
+    "fmt"
+    "os"
+    "sync"
+    "time"
+)
+
+const (
+    WATCH_INTERVAL = 500 * time.Millisecond
+)
+
+// A program whose shader files (including everything they #include) are watched for changes.
+//
+// A background goroutine polls the modification times of the files and reports,
+// as soon as one of them changes. Preprocessing, compiling and linking needs the
+// OpenGL context, so it happens in Update(), which has to be called from the render thread.
+// The new program only replaces Program if it compiled and linked successfully. Otherwise the
+// old program keeps running and the info log is printed.
+// Shaders from ShaderFiles (i.e. compiled into the binary) can not change, so they are not watched.
+type WatchedProgram struct {
+    // Always a valid, linked and introspected program.
+    *Program
+    // Creates a new program and returns all files it depends on.
+    load        func() (uint32, []string, error)
+    // The files can change with every reload (new #include), so they are shared with the watcher.
+    mutex       sync.Mutex
+    files       []string
+    // Modification times of the files at the last poll or reload.
+    times       map[string]time.Time
+    changed     chan bool
+    done        chan bool
+}
+
+func modificationTimes(names []string) map[string]time.Time {
+    times := make(map[string]time.Time, len(names))
+    for _,name := range names {
+        // A file that is missing for a moment (editors saving by renaming) just counts as unchanged.
+        if info, err := os.Stat(name); err == nil {
+            times[name] = info.ModTime()
+        }
+    }
+    return times
+}
+
+func newWatchedProgram(load func() (uint32, []string, error)) (*WatchedProgram, error) {
+    program, files, err := load()
+    if err != nil {
+        return nil, err
+    }
+
+    w := &WatchedProgram {
+        Program: IntrospectProgram(program),
+        load:    load,
+        files:   files,
+        times:   modificationTimes(files),
+        changed: make(chan bool, 1),
+        done:    make(chan bool),
+    }
+    if ShaderFiles == nil {
+        go w.watch()
+    }
+
+    return w, nil
+}
+
+func WatchProgram(vertexShaderName, fragmentShaderName string, defines ShaderDefines) (*WatchedProgram, error) {
+    return newWatchedProgram(func() (uint32, []string, error) {
+        return NewProgramWithDefines(vertexShaderName, fragmentShaderName, defines)
+    })
+}
+
+func WatchComputeProgram(computeShaderName string, defines ShaderDefines) (*WatchedProgram, error) {
+    return newWatchedProgram(func() (uint32, []string, error) {
+        return NewComputeProgramWithDefines(computeShaderName, defines)
+    })
+}
+
+func (w *WatchedProgram) watch() {
+    for {
+        select {
+            case <-w.done:
+                return
+            case <-time.After(WATCH_INTERVAL):
+        }
+
+        w.mutex.Lock()
+        files := w.files
+        w.mutex.Unlock()
+
+        times := modificationTimes(files)
+        changed := false
+        w.mutex.Lock()
+        for name,t := range times {
+            if last, known := w.times[name]; !known || !t.Equal(last) {
+                changed = true
+            }
+            w.times[name] = t
+        }
+        w.mutex.Unlock()
+
+        if changed {
+            // Several changes before the next Update() only need one reload.
+            select {
+                case w.changed <- true:
+                default:
+            }
+        }
+    }
+}
+
+// Recompiles and relinks, if a file changed.
+// Returns true, if Program was replaced by a new program.
+func (w *WatchedProgram) Update() bool {
+    select {
+        case <-w.changed:
+            program, files, err := w.load()
+            if len(files) > 0 {
+                // Files, that are included for the first time, are known from now on.
+                // Otherwise the next poll would see them as changed and reload again.
+                times := modificationTimes(files)
+                w.mutex.Lock()
+                w.files = files
+                for name,t := range times {
+                    if _, known := w.times[name]; !known {
+                        w.times[name] = t
+                    }
+                }
+                w.mutex.Unlock()
+            }
+            if err != nil {
+                fmt.Printf("Reloading %v failed, keeping the old program:\n%v\n", files, err)
+                return false
+            }
+            gl.DeleteProgram(w.ID)
+            w.Program = IntrospectProgram(program)
+            fmt.Printf("Reloaded %v\n", files)
+            return true
+        default:
+            return false
+    }
+}
+
+// Stops watching. The current program stays valid.
+func (w *WatchedProgram) Close() {
+    close(w.done)
+}
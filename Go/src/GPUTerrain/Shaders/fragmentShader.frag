@@ -0,0 +1,100 @@
+#version 430
+
+#include "shadows.glsl"
+#include "triplanar.glsl"
+#include "pbr.glsl"
+
+in vec3 normal;
+in float bakedOcclusion;
+in vec3 pos;
+in vec4 materialOverride;
+in vec4 vertexColor;
+
+uniform vec3 color;
+// Towards the light. The light is directional, like the shadow cascades.
+uniform vec3 lightDirection;
+uniform vec3 cameraPos;
+uniform vec3 viewDirection;
+uniform bool isLight;
+// Triplanar textures instead of color.
+uniform bool textured;
+uniform float alpha;
+// Physically based shading into a HDR buffer. Otherwise the original look.
+uniform bool pbr;
+// Textured surfaces get their roughness from the texture layers instead.
+uniform float surfaceRoughness;
+uniform float surfaceMetallic;
+// Blurred screen space ambient occlusion. Same size as the framebuffer.
+uniform bool ssaoEnabled;
+uniform sampler2D ssaoTexture;
+// Occlusion from the density field, calculated with the terrain.
+uniform bool bakedAoEnabled;
+
+out vec4 colorOut;
+
+void main() {
+    colorOut = vec4(color, 1);
+
+    vec3 l = lightDirection;
+    vec3 n = normalize(normal);
+
+    vec3 baseColor = color;
+    float roughness = surfaceRoughness;
+    if (textured && !isLight) {
+        baseColor = terrainColor(pos, n, materialOverride, roughness);
+    }
+    baseColor = mix(baseColor, vertexColor.rgb, vertexColor.a);
+
+    float visibility = shadowVisibility(pos, dot(pos - cameraPos, viewDirection));
+
+    // Only opaque surfaces are in the occlusion buffer.
+    float ao = 1.0;
+    if (ssaoEnabled && alpha >= 1.0) {
+        ao = texelFetch(ssaoTexture, ivec2(gl_FragCoord.xy), 0).r;
+    }
+    if (bakedAoEnabled) {
+        ao *= bakedOcclusion;
+    }
+
+    if (pbr) {
+        if (isLight) {
+            // Emissive. Bright enough to stay saturated after tone mapping.
+            colorOut = vec4(color*4.0, 1);
+            return;
+        }
+        vec3 v = normalize(cameraPos - pos);
+        // Backsides (i.e. the inside of a transparent surface) are lit like front sides.
+        if (dot(n, v) < 0.0) {
+            n = -n;
+        }
+        // Colours and textures are sRGB. Lighting is done in linear space.
+        vec3 albedo = pow(baseColor, vec3(2.2));
+        colorOut = vec4(shadePBR(pos, n, v, l, visibility, ao, albedo, roughness, surfaceMetallic), alpha);
+        return;
+    }
+
+    vec3 specularColor = baseColor*3;
+    float dotProduct = max(dot(n,l), 0.0);
+    vec3 specular = specularColor * pow(dotProduct, 8.0);
+    specular = clamp(specular, 0.0, 1.0) * visibility;
+
+    vec3 diffuseColor = baseColor*2;
+    vec3 diffuse  = diffuseColor * max(dot(n, l), 0);
+    diffuse = clamp(diffuse, 0.0, 1.0) * visibility;
+
+    vec3 diffuseColorNeg = baseColor*3;
+    vec3 diffuseNeg  = diffuseColorNeg * max(dot(-n, l), 0);
+    diffuseNeg = clamp(diffuseNeg, 0.0, 1.0);
+    diffuseNeg = vec3(1)-diffuseNeg;
+
+    vec3 ambient = baseColor / 1.5;
+
+    if (isLight) {
+        colorOut = vec4(color, 1);
+    } else {
+        colorOut = vec4((diffuseNeg/4 + ambient/4 + baseColor/3)*ao + diffuse/4 + specular/4, alpha);
+    }
+
+}
+
+
@@ -0,0 +1,9 @@
+// Material IDs, shared by the density function and the renderer.
+// Every ID is a layer of the terrain texture array. Same order as TerrainLayerNames in Go.
+
+#define GRASS_LAYER 0
+#define ROCK_LAYER  1
+#define SAND_LAYER  2
+
+// The renderer picks the material by height and slope.
+#define NO_MATERIAL -1
@@ -0,0 +1,190 @@
+// Noise functions for the density. Noise/noise.go has the same functions for the CPU side
+// and both have to be kept in sync! The hashing is done with unsigned integer math
+// and is bit-for-bit identical to the CPU.
+
+const vec3 noiseGradients[12] = vec3[12](
+    vec3(1,1,0), vec3(-1,1,0), vec3(1,-1,0), vec3(-1,-1,0),
+    vec3(1,0,1), vec3(-1,0,1), vec3(1,0,-1), vec3(-1,0,-1),
+    vec3(0,1,1), vec3(0,-1,1), vec3(0,1,-1), vec3(0,-1,-1)
+);
+
+// Integer hash with good avalanche behaviour (lowbias32).
+uint noiseHash(uint x) {
+    x ^= x >> 16;
+    x *= 0x7feb352du;
+    x ^= x >> 15;
+    x *= 0x846ca68bu;
+    x ^= x >> 16;
+    return x;
+}
+
+uint noiseHash3(ivec3 p, uint seed) {
+    return noiseHash(uint(p.x) ^ noiseHash(uint(p.y) ^ noiseHash(uint(p.z) ^ noiseHash(seed))));
+}
+
+// A number in [0,1) from the lower 24 bit of the hash.
+float noiseHashToFloat(uint h) {
+    return float(h & 0xffffffu) / 16777216.0;
+}
+
+float noiseFade(float t) {
+    return t*t*t*(t*(t*6.0 - 15.0) + 10.0);
+}
+
+float noiseMix(float a, float b, float t) {
+    return a + (b - a)*t;
+}
+
+float noiseGradientDot(uint h, vec3 p) {
+    vec3 g = noiseGradients[h%12u];
+    return g.x*p.x + g.y*p.y + g.z*p.z;
+}
+
+// Classic (improved) Perlin gradient noise. Roughly in [-1,1].
+float gradientNoise(vec3 p, uint seed) {
+    vec3 f = floor(p);
+    ivec3 i = ivec3(f);
+    vec3 r = p - f;
+
+    float n000 = noiseGradientDot(noiseHash3(i + ivec3(0,0,0), seed), r - vec3(0,0,0));
+    float n100 = noiseGradientDot(noiseHash3(i + ivec3(1,0,0), seed), r - vec3(1,0,0));
+    float n010 = noiseGradientDot(noiseHash3(i + ivec3(0,1,0), seed), r - vec3(0,1,0));
+    float n110 = noiseGradientDot(noiseHash3(i + ivec3(1,1,0), seed), r - vec3(1,1,0));
+    float n001 = noiseGradientDot(noiseHash3(i + ivec3(0,0,1), seed), r - vec3(0,0,1));
+    float n101 = noiseGradientDot(noiseHash3(i + ivec3(1,0,1), seed), r - vec3(1,0,1));
+    float n011 = noiseGradientDot(noiseHash3(i + ivec3(0,1,1), seed), r - vec3(0,1,1));
+    float n111 = noiseGradientDot(noiseHash3(i + ivec3(1,1,1), seed), r - vec3(1,1,1));
+
+    float u = noiseFade(r.x);
+    float v = noiseFade(r.y);
+    float w = noiseFade(r.z);
+
+    float nx00 = noiseMix(n000, n100, u);
+    float nx10 = noiseMix(n010, n110, u);
+    float nx01 = noiseMix(n001, n101, u);
+    float nx11 = noiseMix(n011, n111, u);
+    float nxy0 = noiseMix(nx00, nx10, v);
+    float nxy1 = noiseMix(nx01, nx11, v);
+
+    return noiseMix(nxy0, nxy1, w);
+}
+
+float simplexCorner(vec3 p, uint h) {
+    float t = 0.6 - p.x*p.x - p.y*p.y - p.z*p.z;
+    if (t <= 0.0) {
+        return 0.0;
+    }
+    t *= t;
+    return t*t*noiseGradientDot(h, p);
+}
+
+// 3D simplex noise. Roughly in [-1,1].
+float simplexNoise(vec3 p, uint seed) {
+    const float F3 = 1.0/3.0;
+    const float G3 = 1.0/6.0;
+
+    float s = (p.x + p.y + p.z)*F3;
+    vec3 f = floor(p + vec3(s));
+    float t = (f.x + f.y + f.z)*G3;
+
+    // Distances from the first corner of the simplex
+    vec3 x0 = p - (f - vec3(t));
+
+    // Which of the six simplices we are in.
+    ivec3 i1;
+    ivec3 i2;
+    if (x0.x >= x0.y) {
+        if      (x0.y >= x0.z) { i1 = ivec3(1,0,0); i2 = ivec3(1,1,0); }
+        else if (x0.x >= x0.z) { i1 = ivec3(1,0,0); i2 = ivec3(1,0,1); }
+        else                   { i1 = ivec3(0,0,1); i2 = ivec3(1,0,1); }
+    } else {
+        if      (x0.y < x0.z)  { i1 = ivec3(0,0,1); i2 = ivec3(0,1,1); }
+        else if (x0.x < x0.z)  { i1 = ivec3(0,1,0); i2 = ivec3(0,1,1); }
+        else                   { i1 = ivec3(0,1,0); i2 = ivec3(1,1,0); }
+    }
+
+    vec3 x1 = x0 - vec3(i1) + vec3(G3);
+    vec3 x2 = x0 - vec3(i2) + vec3(2.0*G3);
+    vec3 x3 = x0 - vec3(1.0) + vec3(3.0*G3);
+
+    ivec3 i = ivec3(f);
+
+    float n = simplexCorner(x0, noiseHash3(i,              seed));
+    n += simplexCorner(x1, noiseHash3(i + i1,         seed));
+    n += simplexCorner(x2, noiseHash3(i + i2,         seed));
+    n += simplexCorner(x3, noiseHash3(i + ivec3(1),   seed));
+
+    return 32.0*n;
+}
+
+// Cellular noise. Returns the distance to the closest (x) and second closest (y) feature point.
+// There is exactly one feature point per unit cell.
+vec2 worleyNoise(vec3 p, uint seed) {
+    vec3 f = floor(p);
+    ivec3 i = ivec3(f);
+
+    float f1 = 100.0;
+    float f2 = 100.0;
+
+    for (int z = -1; z <= 1; z++) {
+        for (int y = -1; y <= 1; y++) {
+            for (int x = -1; x <= 1; x++) {
+                ivec3 cell = i + ivec3(x,y,z);
+                uint h = noiseHash3(cell, seed);
+                // Feature point inside the neighbouring cell
+                vec3 featurePoint = vec3(cell) + vec3(noiseHashToFloat(h), noiseHashToFloat(noiseHash(h)), noiseHashToFloat(noiseHash(noiseHash(h))));
+
+                vec3 diff = featurePoint - p;
+                float d = diff.x*diff.x + diff.y*diff.y + diff.z*diff.z;
+                if (d < f1) {
+                    f2 = f1;
+                    f1 = d;
+                } else if (d < f2) {
+                    f2 = d;
+                }
+            }
+        }
+    }
+
+    return vec2(sqrt(f1), sqrt(f2));
+}
+
+// Fractal brownian motion over gradient noise. Every octave uses its own seed.
+float fbm(vec3 p, uint seed, int octaves, float lacunarity, float gain) {
+    float sum = 0.0;
+    float amplitude = 0.5;
+    for (int i = 0; i < octaves; i++) {
+        sum += amplitude * gradientNoise(p, seed+uint(i));
+        p *= lacunarity;
+        amplitude *= gain;
+    }
+    return sum;
+}
+
+// Ridged multifractal. Sharp ridges where the gradient noise crosses zero.
+// Every octave is weighted by the previous one, so the valleys stay smooth.
+float ridgedNoise(vec3 p, uint seed, int octaves, float lacunarity, float gain, float offset) {
+    float sum = 0.0;
+    float amplitude = 0.5;
+    float previous = 1.0;
+    for (int i = 0; i < octaves; i++) {
+        float n = offset - abs(gradientNoise(p, seed+uint(i)));
+        n *= n;
+        sum += n * amplitude * previous;
+        previous = n;
+        p *= lacunarity;
+        amplitude *= gain;
+    }
+    return sum;
+}
+
+// Offsets p by three independent fBm values. Sampling any noise at the result
+// instead of p gives the typical twisted look of domain warping.
+vec3 domainWarp(vec3 p, uint seed, int octaves, float strength) {
+    vec3 q = vec3(
+        fbm(p,                        seed,    octaves, 2.0, 0.5),
+        fbm(p + vec3(5.2,1.3,2.8),    seed+1u, octaves, 2.0, 0.5),
+        fbm(p + vec3(1.7,9.2,4.1),    seed+2u, octaves, 2.0, 0.5)
+    );
+    return p + q*strength;
+}
@@ -0,0 +1,17 @@
+#version 430
+
+in vec3 viewNormal;
+in vec3 viewPos;
+
+out vec4 normalOut;
+
+void main() {
+
+    vec3 n = normalize(viewNormal);
+    // Backsides are occluded like front sides. The camera is at the origin.
+    if (dot(n, viewPos) > 0.0) {
+        n = -n;
+    }
+    normalOut = vec4(n*0.5 + 0.5, 1);
+
+}
@@ -0,0 +1,20 @@
+#version 430
+
+layout (location = 0) in vec3 vertPos;
+layout (location = 1) in vec3 vertNormal;
+
+uniform mat4 viewProjectionMat;
+uniform mat4 viewMat;
+uniform mat4 modelMat;
+
+out vec3 viewNormal;
+out vec3 viewPos;
+
+void main() {
+
+    // No non-uniform scaling, so the model and view matrix can transform normals directly.
+    viewNormal = mat3(viewMat) * mat3(modelMat) * vertNormal;
+    viewPos = (viewMat * modelMat * vec4(vertPos,1)).xyz;
+    gl_Position = viewProjectionMat * modelMat * vec4(vertPos,1);
+
+}
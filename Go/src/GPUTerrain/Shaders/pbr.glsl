@@ -0,0 +1,81 @@
+// Metallic-roughness shading (Cook-Torrance with GGX, Smith-Schlick and Fresnel-Schlick),
+// as described in "Real Shading in Unreal Engine 4" (Karis).
+
+#ifndef MAX_POINT_LIGHTS
+#define MAX_POINT_LIGHTS 8
+#endif
+
+const float PI = 3.14159265359;
+
+uniform vec3  sunColor;
+uniform int   pointLightCount;
+uniform vec3  pointLightPositions[MAX_POINT_LIGHTS];
+// Already multiplied by the intensity.
+uniform vec3  pointLightColors[MAX_POINT_LIGHTS];
+// The light fades out completely at this distance.
+uniform float pointLightRadii[MAX_POINT_LIGHTS];
+
+// Hemisphere ambient, instead of image based lighting.
+const vec3 skyAmbient    = vec3(0.53, 0.81, 0.92) * 0.35;
+const vec3 groundAmbient = vec3(0.30, 0.25, 0.20) * 0.15;
+
+float distributionGGX(float nDotH, float roughness) {
+    float a2 = roughness*roughness*roughness*roughness;
+    float d = nDotH*nDotH * (a2-1.0) + 1.0;
+    return a2 / (PI * d*d);
+}
+
+float geometrySchlick(float nDotX, float roughness) {
+    float k = (roughness+1.0)*(roughness+1.0) / 8.0;
+    return nDotX / (nDotX * (1.0-k) + k);
+}
+
+vec3 fresnelSchlick(float vDotH, vec3 f0) {
+    return f0 + (1.0-f0) * pow(1.0-vDotH, 5.0);
+}
+
+// Outgoing radiance towards v for light from direction l with the given radiance.
+vec3 brdf(vec3 n, vec3 v, vec3 l, vec3 radiance, vec3 albedo, float roughness, float metallic) {
+    vec3 h = normalize(v+l);
+    float nDotL = max(dot(n,l), 0.0);
+    float nDotV = max(dot(n,v), 0.0001);
+    float nDotH = max(dot(n,h), 0.0);
+    float vDotH = max(dot(v,h), 0.0);
+
+    // Dielectrics reflect about 4% head-on.
+    vec3 f0 = mix(vec3(0.04), albedo, metallic);
+    vec3 F = fresnelSchlick(vDotH, f0);
+    float D = distributionGGX(nDotH, roughness);
+    float G = geometrySchlick(nDotV, roughness) * geometrySchlick(nDotL, roughness);
+
+    vec3 specular = D*G*F / (4.0*nDotV*nDotL + 0.0001);
+    // Metals have no diffuse part.
+    vec3 diffuse = (1.0-F) * (1.0-metallic) * albedo / PI;
+
+    return (diffuse + specular) * radiance * nDotL;
+}
+
+// Inverse square falloff, windowed to reach 0 at the radius (Karis).
+float pointLightAttenuation(float distance, float radius) {
+    float window = clamp(1.0 - pow(distance/radius, 4.0), 0.0, 1.0);
+    return window*window / (distance*distance + 1.0);
+}
+
+// sunVisibility is the shadow term of the sun. Point lights have no shadows.
+// ambientOcclusion only darkens the ambient light.
+vec3 shadePBR(vec3 pos, vec3 n, vec3 v, vec3 sunDirection, float sunVisibility, float ambientOcclusion, vec3 albedo, float roughness, float metallic) {
+
+    vec3 color = brdf(n, v, sunDirection, sunColor * sunVisibility, albedo, roughness, metallic);
+
+    for (int i = 0; i < min(pointLightCount, MAX_POINT_LIGHTS); i++) {
+        vec3 toLight = pointLightPositions[i] - pos;
+        float distance = length(toLight);
+        vec3 radiance = pointLightColors[i] * pointLightAttenuation(distance, pointLightRadii[i]);
+        color += brdf(n, v, toLight/distance, radiance, albedo, roughness, metallic);
+    }
+
+    vec3 ambient = mix(groundAmbient, skyAmbient, n.y*0.5 + 0.5);
+    color += ambient * albedo * (1.0-metallic*0.5) * ambientOcclusion;
+
+    return color;
+}
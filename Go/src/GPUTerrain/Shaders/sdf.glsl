@@ -0,0 +1,39 @@
+// Implicit primitives and their combinations for the density function.
+// Negative is inside, positive is outside and 0 is the surface.
+// Most of them are not exact distances, only the sign and the surface are correct.
+// http://gfs.sourceforge.net/wiki/index.php/GfsSurface
+
+float implicitSphere(vec3 p, float r) {
+    return p.x*p.x + p.y*p.y + p.z*p.z - r*r;
+}
+
+float implicitCube(vec3 p, float r) {
+    return max(abs(p.x), max(abs(p.y), abs(p.z))) - r;
+}
+
+// Infinite cylinders along the x, y and z axis.
+float implicitCylinderX(vec3 p, float r) {
+    return p.z*p.z + p.y*p.y - r*r;
+}
+float implicitCylinderY(vec3 p, float r) {
+    return p.x*p.x + p.z*p.z - r*r;
+}
+float implicitCylinderZ(vec3 p, float r) {
+    return p.x*p.x + p.y*p.y - r*r;
+}
+
+float implicitUnion(float d1, float d2) {
+    return min(d1, d2);
+}
+float implicitIntersection(float d1, float d2) {
+    return max(d1, d2);
+}
+float implicitDifference(float d1, float d2) {
+    return max(d1, -d2);
+}
+
+// Smooth union, blending both surfaces together.
+float densityUnion(float d1, float d2) {
+    float b = 0.4;
+    return -exp(-b*d1) - exp(-b*d2) + 1.;
+}
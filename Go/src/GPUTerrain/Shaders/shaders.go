@@ -0,0 +1,10 @@
+// The GLSL sources of the viewer and mcubes. They are compiled into the binaries, so these run from everywhere.
+// For development, the directory can be given instead (-shaders), which also enables hot reloading.
+package shaders
+
+import (
+    "embed"
+)
+
+//go:embed *.vert *.frag *.comp *.glsl
+var EmbeddedShaders embed.FS
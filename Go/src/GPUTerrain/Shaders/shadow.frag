@@ -0,0 +1,15 @@
+#version 430
+
+// Only used for VSM. PCF only needs the depth buffer.
+out vec2 momentsOut;
+
+void main() {
+
+    float depth = gl_FragCoord.z;
+
+    // The variance within the texel, so slopes do not shadow themselves (Donnelly, Lauritzen).
+    float dx = dFdx(depth);
+    float dy = dFdy(depth);
+    momentsOut = vec2(depth, depth*depth + 0.25*(dx*dx + dy*dy));
+
+}
@@ -0,0 +1,12 @@
+#version 430
+
+layout (location = 0) in vec3 vertPos;
+
+uniform mat4 lightViewProjectionMat;
+uniform mat4 modelMat;
+
+void main() {
+
+    gl_Position = lightViewProjectionMat * modelMat * vec4(vertPos,1);
+
+}
@@ -0,0 +1,93 @@
+// Cascaded shadow maps. SHADOW_CASCADES is injected from Go.
+
+#ifndef SHADOW_CASCADES
+#define SHADOW_CASCADES 3
+#endif
+
+#define PCF_SHADOW_FILTER 0
+#define VSM_SHADOW_FILTER 1
+
+uniform bool      shadowsEnabled;
+uniform int       shadowFilter;
+uniform mat4      lightViewProjectionMats[SHADOW_CASCADES];
+// View space distance, up to which a cascade is used.
+uniform float     cascadeSplits[SHADOW_CASCADES];
+uniform sampler2D shadowDepthMaps[SHADOW_CASCADES];
+uniform sampler2D shadowMomentsMaps[SHADOW_CASCADES];
+
+// Samplers can only be indexed with dynamically uniform expressions. The loop index is one, the cascade is not.
+// textureLod, because implicit derivatives are undefined in non-uniform control flow.
+vec2 sampleMoments(int cascade, vec2 uv) {
+    vec2 moments = vec2(1);
+    for (int i = 0; i < SHADOW_CASCADES; i++) {
+        if (i == cascade) {
+            moments = textureLod(shadowMomentsMaps[i], uv, 0).rg;
+        }
+    }
+    return moments;
+}
+
+float sampleDepth(int cascade, vec2 uv) {
+    float depth = 1;
+    for (int i = 0; i < SHADOW_CASCADES; i++) {
+        if (i == cascade) {
+            depth = textureLod(shadowDepthMaps[i], uv, 0).r;
+        }
+    }
+    return depth;
+}
+
+float pcfVisibility(int cascade, vec3 shadowPos) {
+    vec2 texelSize = 1.0 / vec2(textureSize(shadowDepthMaps[0], 0));
+    // Further cascades cover more area per texel, so they need more bias.
+    float bias = 0.0015 * (cascade+1);
+
+    float visibility = 0.0;
+    for (int x = -1; x <= 1; x++) {
+        for (int y = -1; y <= 1; y++) {
+            float depth = sampleDepth(cascade, shadowPos.xy + vec2(x,y)*texelSize);
+            visibility += shadowPos.z - bias > depth ? 0.0 : 1.0;
+        }
+    }
+    return visibility / 9.0;
+}
+
+float vsmVisibility(int cascade, vec3 shadowPos) {
+    vec2 moments = sampleMoments(cascade, shadowPos.xy);
+    if (shadowPos.z <= moments.x) {
+        return 1.0;
+    }
+    float variance = max(moments.y - moments.x*moments.x, 0.00002);
+    float d = shadowPos.z - moments.x;
+    float pMax = variance / (variance + d*d);
+    // Cuts off the tail of the Chebyshev bound, which shows as light bleeding.
+    return smoothstep(0.3, 1.0, pMax);
+}
+
+// 1 is fully lit, 0 is in shadow.
+float shadowVisibility(vec3 worldPos, float viewDepth) {
+    if (!shadowsEnabled) {
+        return 1.0;
+    }
+
+    int cascade = -1;
+    for (int i = SHADOW_CASCADES-1; i >= 0; i--) {
+        if (viewDepth <= cascadeSplits[i]) {
+            cascade = i;
+        }
+    }
+    if (cascade < 0) {
+        return 1.0;
+    }
+
+    // Orthographic, so no division by w.
+    vec3 shadowPos = (lightViewProjectionMats[cascade] * vec4(worldPos, 1)).xyz * 0.5 + 0.5;
+    if (any(lessThan(shadowPos, vec3(0))) || any(greaterThan(shadowPos, vec3(1)))) {
+        return 1.0;
+    }
+
+    if (shadowFilter == VSM_SHADOW_FILTER) {
+        return vsmVisibility(cascade, shadowPos);
+    }
+    return pcfVisibility(cascade, shadowPos);
+}
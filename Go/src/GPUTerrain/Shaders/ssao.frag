@@ -0,0 +1,61 @@
+#version 430
+
+// Screen space ambient occlusion with a normal oriented hemisphere (Crytek, Chapman).
+
+#ifndef MAX_SSAO_SAMPLES
+#define MAX_SSAO_SAMPLES 64
+#endif
+
+in vec2 uv;
+
+uniform sampler2D depthTexture;
+uniform sampler2D normalTexture;
+uniform mat4  projectionMat;
+uniform mat4  inverseProjectionMat;
+uniform vec3  ssaoKernel[MAX_SSAO_SAMPLES];
+uniform int   sampleCount;
+uniform float radius;
+
+out float aoOut;
+
+vec3 viewPosition(vec2 texCoord) {
+    float depth = texture(depthTexture, texCoord).r;
+    vec4 p = inverseProjectionMat * vec4(vec3(texCoord, depth)*2.0 - 1.0, 1);
+    return p.xyz / p.w;
+}
+
+void main() {
+
+    if (texture(depthTexture, uv).r >= 1.0) {
+        aoOut = 1.0;
+        return;
+    }
+
+    vec3 p = viewPosition(uv);
+    vec3 n = normalize(texture(normalTexture, uv).xyz*2.0 - 1.0);
+
+    // Rotates the kernel per pixel (interleaved gradient noise, Jimenez). The blur removes the pattern.
+    float angle = 6.2831853 * fract(52.9829189 * fract(dot(gl_FragCoord.xy, vec2(0.06711056, 0.00583715))));
+    vec3 r = vec3(cos(angle), sin(angle), 0);
+    vec3 tangent = normalize(r - n*dot(r, n));
+    mat3 tbn = mat3(tangent, cross(n, tangent), n);
+
+    // Avoids self occlusion of flat surfaces.
+    float bias = 0.025 * radius;
+    float occlusion = 0.0;
+    int count = min(sampleCount, MAX_SSAO_SAMPLES);
+    for (int i = 0; i < count; i++) {
+        vec3 s = p + tbn * ssaoKernel[i] * radius;
+
+        vec4 offset = projectionMat * vec4(s, 1);
+        vec2 sampleUV = offset.xy/offset.w * 0.5 + 0.5;
+        float sampleDepth = viewPosition(sampleUV).z;
+
+        // Geometry far in front of p does not occlude it.
+        float rangeCheck = smoothstep(0.0, 1.0, radius / abs(p.z - sampleDepth));
+        occlusion += (sampleDepth >= s.z + bias ? 1.0 : 0.0) * rangeCheck;
+    }
+
+    aoOut = 1.0 - occlusion / max(count, 1);
+
+}
@@ -0,0 +1,22 @@
+#version 430
+
+// Box blur over the 4x4 texels, the noise pattern of the SSAO repeats in.
+
+uniform sampler2D aoTexture;
+
+out float aoOut;
+
+void main() {
+
+    ivec2 size = textureSize(aoTexture, 0);
+    ivec2 p = ivec2(gl_FragCoord.xy);
+
+    float sum = 0.0;
+    for (int x = -2; x < 2; x++) {
+        for (int y = -2; y < 2; y++) {
+            sum += texelFetch(aoTexture, clamp(p + ivec2(x,y), ivec2(0), size-1), 0).r;
+        }
+    }
+    aoOut = sum / 16.0;
+
+}
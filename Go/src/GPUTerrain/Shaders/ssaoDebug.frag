@@ -0,0 +1,15 @@
+#version 430
+
+// Shows the blurred occlusion buffer alone.
+
+in vec2 uv;
+
+uniform sampler2D aoTexture;
+
+out vec4 colorOut;
+
+void main() {
+
+    colorOut = vec4(vec3(texture(aoTexture, uv).r), 1);
+
+}
@@ -0,0 +1,21 @@
+#version 430
+
+in vec2 uv;
+
+uniform sampler2D hdrTexture;
+uniform float exposure;
+
+out vec4 colorOut;
+
+// Filmic curve fitted to ACES (Narkowicz).
+vec3 tonemapACES(vec3 x) {
+    return clamp((x*(2.51*x + 0.03)) / (x*(2.43*x + 0.59) + 0.14), 0.0, 1.0);
+}
+
+void main() {
+
+    vec3 hdr = texture(hdrTexture, uv).rgb * exposure;
+    vec3 ldr = tonemapACES(hdr);
+    colorOut = vec4(pow(ldr, vec3(1.0/2.2)), 1);
+
+}
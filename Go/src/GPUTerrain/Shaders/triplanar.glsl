@@ -0,0 +1,50 @@
+// Triplanar texturing of the terrain. The extracted mesh has no UVs, so every texture is projected
+// along the three axes and blended by the normal.
+
+#include "materials.glsl"
+
+uniform sampler2DArray terrainTextures;
+
+// Texture repetitions per world unit.
+const float textureScale       = 1.0/8.0;
+// Higher values make the transitions between the three projections sharper.
+const float triplanarSharpness = 4.0;
+// Sand below this height. Blended over sandBlend in both directions.
+const float sandHeight         = 4.0;
+const float sandBlend          = 1.0;
+// Rock between these slopes (0 is flat, 1 is a vertical wall).
+const float rockSlopeStart     = 0.3;
+const float rockSlopeEnd       = 0.5;
+// For physically based shading. Grass, rock and sand.
+const vec3  layerRoughness     = vec3(0.85, 0.65, 0.95);
+
+vec3 triplanarSample(vec3 pos, vec3 weights, int layer) {
+    vec3 x = texture(terrainTextures, vec3(pos.zy*textureScale, layer)).rgb;
+    vec3 y = texture(terrainTextures, vec3(pos.xz*textureScale, layer)).rgb;
+    vec3 z = texture(terrainTextures, vec3(pos.xy*textureScale, layer)).rgb;
+    return x*weights.x + y*weights.y + z*weights.z;
+}
+
+// Weights of grass, rock and sand by height and slope. They add up to 1.
+vec3 materialWeights(vec3 pos, vec3 normal) {
+    // Overhangs count as vertical.
+    float slope = 1.0 - max(normal.y, 0.0);
+    float rock  = smoothstep(rockSlopeStart, rockSlopeEnd, slope);
+    float sand  = (1.0-rock) * (1.0 - smoothstep(sandHeight-sandBlend, sandHeight+sandBlend, pos.y));
+    return vec3(1.0-rock-sand, rock, sand);
+}
+
+// materialOverride are the weights of the material IDs from the density function (xyz)
+// and how much they replace the height and slope rules (w).
+vec3 terrainColor(vec3 pos, vec3 normal, vec4 materialOverride, out float roughness) {
+    vec3 projectionWeights = pow(abs(normal), vec3(triplanarSharpness));
+    projectionWeights /= projectionWeights.x + projectionWeights.y + projectionWeights.z;
+
+    vec3 materials = materialWeights(pos, normal) * (1.0-materialOverride.w) + materialOverride.xyz;
+    roughness = dot(materials, layerRoughness);
+
+    // All layers are sampled unconditionally. texture() needs uniform control flow for the mipmap level.
+    return materials.x * triplanarSample(pos, projectionWeights, GRASS_LAYER) +
+           materials.y * triplanarSample(pos, projectionWeights, ROCK_LAYER) +
+           materials.z * triplanarSample(pos, projectionWeights, SAND_LAYER);
+}
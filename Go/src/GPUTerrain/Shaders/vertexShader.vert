@@ -0,0 +1,43 @@
+#version 430
+
+#include "materials.glsl"
+
+// w is the material ID of extracted vertices.
+layout (location = 0) in vec4 vertPos;
+// w is the baked ambient occlusion of extracted vertices. Other objects only have a vec3, so w is 1.
+layout (location = 1) in vec4 vertNormal;
+layout (location = 2) in vec4 vertColor;
+
+// Normal attributes
+uniform mat4 viewProjectionMat;
+uniform mat4 modelMat;
+// Only extracted vertices have materials. Other objects have no colour attribute and w of their position is 1.
+uniform bool vertexMaterials;
+
+out vec3 normal;
+out float bakedOcclusion;
+out vec3 pos;
+// Interpolated, so neighbouring materials blend into each other.
+out vec4 materialOverride;
+out vec4 vertexColor;
+
+void main() {
+
+    normal = normalize(vertNormal.xyz);
+    bakedOcclusion = vertNormal.w;
+    pos = (modelMat * vec4(vertPos.xyz,1)).xyz;
+
+    materialOverride = vec4(0);
+    vertexColor = vec4(0);
+    if (vertexMaterials) {
+        int id = int(vertPos.w);
+        if (id != NO_MATERIAL) {
+            materialOverride = vec4(id == GRASS_LAYER, id == ROCK_LAYER, id == SAND_LAYER, 1);
+        }
+        vertexColor = vertColor;
+    }
+
+    gl_Position = viewProjectionMat * modelMat * (vec4(vertPos.xyz,1));
+
+}
+
@@ -0,0 +1,134 @@
+package terrain
+
+// The terrain of marchingCubes.comp, shared by the viewer and the mcubes command:
+// The names of the density presets, the parameters of the density function and a CPU version of it
+// and of the materials.
+
+import (
+    . "GPUTerrain/Mesher"
+    . "GPUTerrain/Noise"
+    . "GPUTerrain/OpenGL"
+    "github.com/go-gl/mathgl/mgl32"
+    "fmt"
+    "math"
+)
+
+// Texture layers of materials.glsl.
+const RockLayer = 1
+
+// The density functions of the compute shader. Same order as the *_PRESET defines in marchingCubes.comp.
+var DensityPresets = []string{"terrain", "csg", "gyroid", "waves"}
+
+func DensityPresetIndex(name string) (int, error) {
+    for i,preset := range DensityPresets {
+        if preset == name {
+            return i, nil
+        }
+    }
+    return 0, fmt.Errorf("unknown density preset %q (one of %v)", name, DensityPresets)
+}
+
+// All literals of the density function. They are uploaded as uniforms with the same name.
+func DeclareTerrainParameters() *ParameterRegistry {
+    parameters := NewParameterRegistry()
+
+    parameters.DeclareFloat("floorHeight",  5.0,  0.25)
+    parameters.DeclareFloat("cylinderR",    2.0,  0.1)
+    parameters.DeclareFloat("sphereR",      4.5,  0.1)
+    parameters.DeclareFloat("cubeR",        3.0,  0.1)
+    parameters.DeclareFloat("fx",           0.5,  0.01)
+    parameters.DeclareFloat("fz",           0.35, 0.01)
+    parameters.DeclareVec3 ("noiseScale",   mgl32.Vec3{0.03, 0.06, 0.03}, 0.005)
+    parameters.DeclareFloat("warpStrength", 0.6,  0.05)
+    parameters.DeclareFloat("hillHeight",   4.0,  0.25)
+    parameters.DeclareFloat("ridgeHeight",  2.0,  0.25)
+    // Not part of the density function, but the baked occlusion is calculated with the terrain as well.
+    parameters.DeclareFloat("aoDistance",   6.0,  0.5)
+
+    return parameters
+}
+
+func sin(f float32) float32 {
+    return float32(math.Sin(float64(f)))
+}
+func cos(f float32) float32 {
+    return float32(math.Cos(float64(f)))
+}
+
+// CPU version of getDensityAtPosition in marchingCubes.comp with the current parameter values.
+// Has to be changed together with the shader!
+func CpuDensity(preset int, parameters *ParameterRegistry) Density {
+    value := func(name string) mgl32.Vec3 {
+        p, err := parameters.Get(name)
+        if err != nil {
+            panic(err)
+        }
+        return p.Value
+    }
+    floorHeight  := value("floorHeight")[0]
+    cylinderR    := value("cylinderR")[0]
+    sphereR      := value("sphereR")[0]
+    cubeR        := value("cubeR")[0]
+    fx           := value("fx")[0]
+    fz           := value("fz")[0]
+    noiseScale   := value("noiseScale")
+    warpStrength := value("warpStrength")[0]
+    hillHeight   := value("hillHeight")[0]
+    ridgeHeight  := value("ridgeHeight")[0]
+
+    return func(pos mgl32.Vec3) float32 {
+        x, y, z := pos[0], pos[1], pos[2]
+
+        floor := y - floorHeight
+        gyroid := cos(x)*sin(y) + cos(y)*sin(z) + cos(z)*sin(x)
+
+        // Move everything to the center!
+        x -= 5.0
+        y -= 5.0
+        z -= 5.0
+        centered := mgl32.Vec3{x, y, z}
+
+        switch preset {
+            case 1:
+                sphere := ImplicitSphere(centered, sphereR)
+                cube := ImplicitCube(centered, cubeR)
+                cylinder1 := ImplicitCylinderZ(centered, cylinderR)
+                cylinder2 := ImplicitCylinderX(centered, cylinderR)
+                cylinder3 := ImplicitCylinderY(centered, cylinderR)
+                cylinderU := ImplicitUnion(cylinder1, ImplicitUnion(cylinder2, cylinder3))
+                return ImplicitDifference(ImplicitIntersection(sphere, cube), cylinderU)
+            case 2:
+                return DensityUnion(floor, gyroid)
+            case 3:
+                return sin(x*fx) + floor + cos(z*fz)
+        }
+
+        warped := DomainWarp(mgl32.Vec3{pos[0]*noiseScale[0], pos[1]*noiseScale[1], pos[2]*noiseScale[2]}, 1, 3, warpStrength)
+        hills := Fbm(warped, 7, 5, 2.0, 0.5) * hillHeight
+        ridges := Ridged(warped.Mul(2.0), 13, 4, 2.0, 0.5, 1.0) * ridgeHeight
+        return floor - hills - ridges
+    }
+}
+
+// CPU version of getMaterialAtPosition in marchingCubes.comp. The same for every density preset.
+// Has to be changed together with the shader!
+func CpuMaterials(parameters *ParameterRegistry) Materials {
+    value := func(name string) mgl32.Vec3 {
+        p, err := parameters.Get(name)
+        if err != nil {
+            panic(err)
+        }
+        return p.Value
+    }
+    noiseScale   := value("noiseScale")
+    warpStrength := value("warpStrength")[0]
+
+    return func(pos mgl32.Vec3) Material {
+        // The ridges of the terrain are bare rock.
+        warped := DomainWarp(mgl32.Vec3{pos[0]*noiseScale[0], pos[1]*noiseScale[1], pos[2]*noiseScale[2]}, 1, 3, warpStrength)
+        if Ridged(warped.Mul(2.0), 13, 4, 2.0, 0.5, 1.0) > 0.6 {
+            return Material{ID: RockLayer}
+        }
+        return Material{ID: NoMaterial}
+    }
+}
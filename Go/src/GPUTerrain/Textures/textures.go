@@ -0,0 +1,152 @@
+package textures
+
+import (
+    . "GPUTerrain/Noise"
+    "github.com/go-gl/mathgl/mgl32"
+    "fmt"
+    "image"
+    "image/color"
+    "image/draw"
+    "image/png"
+    "math"
+    "os"
+    "path/filepath"
+)
+
+// The layers of the terrain texture array. Same order as the layers in triplanar.glsl.
+var TerrainLayerNames = []string{"grass", "rock", "sand"}
+
+// Loads <directory>/<layer>.png for every terrain layer. Missing files are generated procedurally,
+// so the terrain is textured without any assets. Images of a different size are scaled to size x size.
+func TerrainTextures(directory string, size int) ([]*image.RGBA, error) {
+    layers := make([]*image.RGBA, len(TerrainLayerNames))
+    for i,name := range TerrainLayerNames {
+        fileName := filepath.Join(directory, name+".png")
+        if _, err := os.Stat(fileName); err != nil {
+            layers[i] = generateLayer(name, size)
+            continue
+        }
+        img, err := loadPNG(fileName)
+        if err != nil {
+            return nil, err
+        }
+        layers[i] = scale(img, size)
+    }
+    return layers, nil
+}
+
+func loadPNG(fileName string) (image.Image, error) {
+    file, err := os.Open(fileName)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+
+    img, err := png.Decode(file)
+    if err != nil {
+        return nil, fmt.Errorf("%v: %v", fileName, err)
+    }
+    return img, nil
+}
+
+// Nearest neighbour. Good enough, the texture array gets mipmaps anyway.
+func scale(img image.Image, size int) *image.RGBA {
+    bounds := img.Bounds()
+    rgba := image.NewRGBA(image.Rect(0, 0, size, size))
+    if bounds.Dx() == size && bounds.Dy() == size {
+        draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+        return rgba
+    }
+    for y := 0; y < size; y++ {
+        for x := 0; x < size; x++ {
+            rgba.Set(x, y, img.At(bounds.Min.X + x*bounds.Dx()/size, bounds.Min.Y + y*bounds.Dy()/size))
+        }
+    }
+    return rgba
+}
+
+func generateLayer(name string, size int) *image.RGBA {
+    switch name {
+        case "grass":
+            return GenerateGrass(size)
+        case "rock":
+            return GenerateRock(size)
+        case "sand":
+            return GenerateSand(size)
+    }
+    panic("no generator for terrain layer " + name)
+}
+
+// Makes f repeat every period in x and y, by blending it with its copies shifted by one period.
+// The weights go to 0 towards the copies, so opposite borders match exactly.
+func tileable(f func(x, y float32) float32, x, y, period float32) float32 {
+    u := x/period
+    v := y/period
+    return f(x, y)               * (1-u) * (1-v) +
+           f(x-period, y)        * u     * (1-v) +
+           f(x, y-period)        * (1-u) * v     +
+           f(x-period, y-period) * u     * v
+}
+
+func generate(size int, pixel func(x, y float32) mgl32.Vec3) *image.RGBA {
+    img := image.NewRGBA(image.Rect(0, 0, size, size))
+    for y := 0; y < size; y++ {
+        for x := 0; x < size; x++ {
+            c := pixel(float32(x), float32(y))
+            img.SetRGBA(x, y, color.RGBA{toByte(c.X()), toByte(c.Y()), toByte(c.Z()), 255})
+        }
+    }
+    return img
+}
+
+func toByte(f float32) uint8 {
+    return uint8(math.Max(0, math.Min(255, float64(f)*255.0 + 0.5)))
+}
+
+func mixColor(a, b mgl32.Vec3, t float32) mgl32.Vec3 {
+    t = float32(math.Max(0, math.Min(1, float64(t))))
+    return a.Mul(1-t).Add(b.Mul(t))
+}
+
+func GenerateGrass(size int) *image.RGBA {
+    period := float32(size)
+    patches := func(x, y float32) float32 { return Fbm(mgl32.Vec3{x/32, y/32, 0.5}, 11, 4, 2.0, 0.5) }
+    blades  := func(x, y float32) float32 { return Gradient(mgl32.Vec3{x/1.5, y/6, 0.5}, 12) }
+
+    return generate(size, func(x, y float32) mgl32.Vec3 {
+        c := mixColor(mgl32.Vec3{0.20,0.38,0.10}, mgl32.Vec3{0.38,0.52,0.16}, tileable(patches, x, y, period)*1.5 + 0.5)
+        return c.Mul(0.85 + 0.3*tileable(blades, x, y, period))
+    })
+}
+
+func GenerateRock(size int) *image.RGBA {
+    period := float32(size)
+    strata := func(x, y float32) float32 { return Ridged(mgl32.Vec3{x/48, y/24, 0.5}, 21, 4, 2.0, 0.5, 1.0) }
+    grain  := func(x, y float32) float32 { return Fbm(mgl32.Vec3{x/4, y/4, 0.5}, 22, 3, 2.0, 0.5) }
+    cracks := func(x, y float32) float32 {
+        f1, f2 := Worley(mgl32.Vec3{x/24, y/24, 0.5}, 23)
+        return f2 - f1
+    }
+
+    return generate(size, func(x, y float32) mgl32.Vec3 {
+        c := mixColor(mgl32.Vec3{0.30,0.29,0.27}, mgl32.Vec3{0.55,0.53,0.50}, tileable(strata, x, y, period))
+        c = c.Mul(0.9 + 0.4*tileable(grain, x, y, period))
+        // Dark lines along the cell borders.
+        crack := float32(math.Min(1, float64(tileable(cracks, x, y, period))*8))
+        return c.Mul(0.55 + 0.45*crack)
+    })
+}
+
+func GenerateSand(size int) *image.RGBA {
+    period := float32(size)
+    dunes := func(x, y float32) float32 { return Fbm(mgl32.Vec3{x/40, y/40, 0.5}, 31, 3, 2.0, 0.5) }
+    grain := func(x, y float32) float32 { return Gradient(mgl32.Vec3{x, y, 0.5}, 32) }
+
+    return generate(size, func(x, y float32) mgl32.Vec3 {
+        // Whole number of ripples per period, so they tile as well.
+        warp := tileable(dunes, x, y, period)
+        ripples := float32(math.Sin(float64((y/period)*2*math.Pi*12 + warp*6)))
+        c := mixColor(mgl32.Vec3{0.76,0.66,0.45}, mgl32.Vec3{0.88,0.80,0.60}, 0.5 + 0.25*ripples + warp)
+        return c.Mul(0.92 + 0.16*tileable(grain, x, y, period))
+    })
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+    . "GPUTerrain/Camera"
+    "fmt"
+    "os"
+    "path/filepath"
+)
+
+// Camera poses of g_bookmarkFile. B adds the current pose, 1-9 go to one of them.
+var g_bookmarks []Bookmark
+// The path through all bookmarks, that the camera follows right now. P starts and stops it.
+var g_cameraPath *CameraPath
+var g_cameraPathTime float32
+
+// A broken bookmark file is an error at startup. Otherwise B would overwrite it with the new bookmark only.
+func loadBookmarks() error {
+    bookmarks, err := LoadBookmarks(*g_bookmarkFile)
+    if err != nil {
+        return err
+    }
+    g_bookmarks = bookmarks
+    return nil
+}
+
+func addBookmark() {
+    g_bookmarks = append(g_bookmarks, g_camera.Bookmark(fmt.Sprintf("bookmark %v", len(g_bookmarks)+1)))
+    if err := SaveBookmarks(*g_bookmarkFile, g_bookmarks); err != nil {
+        fmt.Println(err)
+        return
+    }
+    fmt.Printf("saved bookmark %v to %v\n", len(g_bookmarks), *g_bookmarkFile)
+}
+
+// index starts at 0.
+func goToBookmark(index int) {
+    if index >= len(g_bookmarks) {
+        fmt.Printf("there are only %v bookmarks\n", len(g_bookmarks))
+        return
+    }
+    g_cameraPath = nil
+    g_camera.GoTo(g_bookmarks[index])
+}
+
+func toggleCameraPath() {
+    if g_cameraPath != nil {
+        g_cameraPath = nil
+        return
+    }
+    path, err := NewCameraPath(g_bookmarks, float32(*g_segmentDuration))
+    if err != nil {
+        fmt.Println(err)
+        return
+    }
+    g_cameraPath = path
+    g_cameraPathTime = 0
+    fmt.Printf("following %v bookmarks for %.1fs\n", len(g_bookmarks), path.Duration())
+}
+
+// Moves the camera one frame along the path. The time step is fixed, so every run
+// shows the same frames, no matter how long they take to render.
+func followCameraPath() {
+    if g_cameraPath == nil {
+        return
+    }
+    g_camera.GoTo(g_cameraPath.At(g_cameraPathTime))
+    g_cameraPathTime += 1.0 / float32(*g_pathFps)
+    if g_cameraPathTime > g_cameraPath.Duration() {
+        g_cameraPath = nil
+    }
+}
+
+// Renders every frame of the path through all bookmarks into g_sequenceDir.
+func renderSequence() error {
+    path, err := NewCameraPath(g_bookmarks, float32(*g_segmentDuration))
+    if err != nil {
+        return fmt.Errorf("%v: %v", *g_bookmarkFile, err)
+    }
+    if err := os.MkdirAll(*g_sequenceDir, 0755); err != nil {
+        return err
+    }
+
+    frameCount := path.FrameCount(float32(*g_pathFps))
+    for frame := 0; frame < frameCount; frame++ {
+        g_camera.GoTo(path.At(float32(frame) / float32(*g_pathFps)))
+        calculateAndRenderMarchingCubes()
+
+        fileName := filepath.Join(*g_sequenceDir, fmt.Sprintf("frame_%05d.png", frame))
+        if err := saveScreenshot(fileName); err != nil {
+            return err
+        }
+    }
+    fmt.Printf("saved %v frames to %v\n", frameCount, *g_sequenceDir)
+    return nil
+}
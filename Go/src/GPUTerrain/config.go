@@ -0,0 +1,209 @@
+package main
+
+// Startup settings of the viewer. Every setting has a default, which the JSON file of -config
+// overrides, which the command-line flags override. A config file only needs the settings it changes:
+//
+//    {
+//        "windowWidth": 1600,
+//        "windowHeight": 900,
+//        "units": [8, 1, 8],
+//        "chunkSize": [32, 16, 32],
+//        "vsync": true
+//    }
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+type Config struct {
+    WindowWidth         int         `json:"windowWidth"`
+    WindowHeight        int         `json:"windowHeight"`
+    // Vertical field of view in degrees.
+    Fovy                float64     `json:"fovy"`
+    NearPlane           float64     `json:"nearPlane"`
+    FarPlane            float64     `json:"farPlane"`
+    // Number of marching cubes units in x, y and z.
+    Units               [3]int      `json:"units"`
+    // Number of cubes per unit in x, y and z (CHUNK_SIZE in the compute shader).
+    ChunkSize           [3]int      `json:"chunkSize"`
+    // First guess for the size of the position buffer. It grows, if the terrain needs more.
+    TrianglesPerCube    float64     `json:"trianglesPerCube"`
+    VSync               bool        `json:"vsync"`
+    LightPos            [3]float64  `json:"lightPos"`
+    // Directory of the shaders (and the textures directory), which are then reloaded on changes.
+    // Empty uses the shaders compiled into the binary. Relative paths are tried from the working
+    // directory first and then from the directory of the binary.
+    ShaderPath          string      `json:"shaderPath"`
+}
+
+var g_config = Config {
+    WindowWidth:        1000,
+    WindowHeight:       1000,
+    Fovy:               90.0,
+    NearPlane:          0.1,
+    FarPlane:           2000.0,
+    Units:              [3]int{15, 1, 15},
+    ChunkSize:          [3]int{10, 10, 10},
+    TrianglesPerCube:   2.0,
+    VSync:              false,
+    LightPos:           [3]float64{3, 80, 0},
+    ShaderPath:         "",
+}
+
+// Upper limit of ChunkSize per axis, so a typo does not allocate gigabytes of buffers.
+const g_maxChunkSize = 256
+
+// The source tree as seen from bin/. Golden images and shaders for development are in there.
+const g_sourcePath = "../Go/src/GPUTerrain/"
+
+var g_configFile = flag.String("config", "", "JSON file with startup settings. Flags given as well win over the file")
+
+func init() {
+    flag.IntVar(&g_config.WindowWidth, "width", g_config.WindowWidth, "window width")
+    flag.IntVar(&g_config.WindowHeight, "height", g_config.WindowHeight, "window height")
+    flag.Float64Var(&g_config.Fovy, "fov", g_config.Fovy, "vertical field of view in degrees")
+    flag.Float64Var(&g_config.NearPlane, "near", g_config.NearPlane, "near plane")
+    flag.Float64Var(&g_config.FarPlane, "far", g_config.FarPlane, "far plane")
+    flag.Var((*intsValue)(&g_config.Units), "units", "marching cubes units in x,y,z")
+    flag.Var((*intsValue)(&g_config.ChunkSize), "chunk-size", "cubes per marching cubes unit in x,y,z")
+    flag.Float64Var(&g_config.TrianglesPerCube, "triangles-per-cube", g_config.TrianglesPerCube, "initial size of the position buffer in triangles per cube")
+    flag.BoolVar(&g_config.VSync, "vsync", g_config.VSync, "wait for the vertical sync")
+    flag.Var((*floatsValue)(&g_config.LightPos), "light", "position of the sun as x,y,z")
+    flag.StringVar(&g_config.ShaderPath, "shaders", g_config.ShaderPath, "directory of the shaders to load (and hot reload) instead of the compiled in ones, i.e. "+g_sourcePath+"Shaders/")
+}
+
+// Three comma separated components for the flags.
+type intsValue [3]int
+type floatsValue [3]float64
+
+func splitComponents(s string) ([]string, error) {
+    components := strings.Split(s, ",")
+    if len(components) != 3 {
+        return nil, fmt.Errorf("%q needs three comma separated components", s)
+    }
+    for i,c := range components {
+        components[i] = strings.TrimSpace(c)
+    }
+    return components, nil
+}
+
+func (v *intsValue) String() string {
+    return fmt.Sprintf("%v,%v,%v", v[0], v[1], v[2])
+}
+
+func (v *intsValue) Set(s string) error {
+    components, err := splitComponents(s)
+    if err != nil {
+        return err
+    }
+    for i,c := range components {
+        if v[i], err = strconv.Atoi(c); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (v *floatsValue) String() string {
+    return fmt.Sprintf("%v,%v,%v", v[0], v[1], v[2])
+}
+
+func (v *floatsValue) Set(s string) error {
+    components, err := splitComponents(s)
+    if err != nil {
+        return err
+    }
+    for i,c := range components {
+        if v[i], err = strconv.ParseFloat(c, 64); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// Overwrites the settings, that are in the file.
+func (c *Config) Load(fileName string) error {
+    file, err := os.Open(fileName)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    decoder := json.NewDecoder(file)
+    decoder.DisallowUnknownFields()
+    if err := decoder.Decode(c); err != nil {
+        return fmt.Errorf("%v: %v", fileName, err)
+    }
+    return nil
+}
+
+func (c *Config) Validate() error {
+    if c.WindowWidth <= 0 || c.WindowHeight <= 0 {
+        return fmt.Errorf("window size %vx%v is empty", c.WindowWidth, c.WindowHeight)
+    }
+    if c.Fovy <= 0 || c.Fovy >= 180 {
+        return fmt.Errorf("field of view %v has to be between 0 and 180 degrees", c.Fovy)
+    }
+    if c.NearPlane <= 0 || c.FarPlane <= c.NearPlane {
+        return fmt.Errorf("near plane %v and far plane %v have to be 0 < near < far", c.NearPlane, c.FarPlane)
+    }
+    for _,count := range c.Units {
+        if count <= 0 {
+            return fmt.Errorf("unit counts %v have to be positive", c.Units)
+        }
+    }
+    for _,size := range c.ChunkSize {
+        if size <= 0 || size > g_maxChunkSize {
+            return fmt.Errorf("chunk size %v has to be between 1 and %v cubes per axis", c.ChunkSize, g_maxChunkSize)
+        }
+    }
+    if c.TrianglesPerCube < 0 {
+        return fmt.Errorf("triangles per cube %v is negative", c.TrianglesPerCube)
+    }
+    return nil
+}
+
+// Relative paths are relative to the working directory, if they exist there.
+// Otherwise relative to the binary, so bin/GPUTerrain finds ../Go/src/GPUTerrain/ from everywhere.
+func resolvePath(path string) string {
+    if !filepath.IsAbs(path) {
+        if _, err := os.Stat(path); err != nil {
+            if executable, err := os.Executable(); err == nil {
+                executable, _ = filepath.EvalSymlinks(executable)
+                fromBinary := filepath.Join(filepath.Dir(executable), path)
+                if _, err := os.Stat(fromBinary); err == nil {
+                    path = fromBinary
+                }
+            }
+        }
+    }
+    // File names are appended directly.
+    if !strings.HasSuffix(path, string(filepath.Separator)) {
+        path += string(filepath.Separator)
+    }
+    return path
+}
+
+// Parses the flags and the config file. Flags win, so they are parsed again after loading the file.
+func parseConfig() error {
+    flag.Parse()
+    if *g_configFile != "" {
+        if err := g_config.Load(*g_configFile); err != nil {
+            return err
+        }
+        flag.Parse()
+    }
+    if g_config.ShaderPath != "" {
+        g_config.ShaderPath = resolvePath(g_config.ShaderPath)
+    }
+    if *g_goldenDir == "" {
+        *g_goldenDir = filepath.Join(resolvePath(g_sourcePath), "Golden", "images")
+    }
+    return g_config.Validate()
+}
@@ -4,11 +4,16 @@ import (
     . "GPUTerrain/Geometry"
     . "GPUTerrain/Camera"
     . "GPUTerrain/OpenGL"
+    . "GPUTerrain/Textures"
+    . "GPUTerrain/Headless"
+    . "GPUTerrain/Mesher"
+    . "GPUTerrain/Terrain"
+    . "GPUTerrain/Shaders"
     "runtime"
     "github.com/go-gl/mathgl/mgl32"
     "fmt"
-    "C"
-    "unsafe"
+    "flag"
+    "os"
     "github.com/go-gl/gl/v4.5-core/gl"
     "github.com/go-gl/glfw/v3.2/glfw"
     //"github.com/MauriceGit/half"
@@ -17,35 +22,132 @@ import (
 // Constants and global variables
 
 const (
-    g_WindowWidth  = 1000
-    g_WindowHeight = 1000
 
-    g_cubeWidth    = 10
-    g_cubeHeight   = 10
-    g_cubeDepth    = 10
+    // Local size of the compute shader. Independent of the unit size (-chunk-size),
+    // units, that are no multiple of it, get partial work groups at their border.
+    g_workGroupSizeX = 8
+    g_workGroupSizeY = 8
+    g_workGroupSizeZ = 8
+
+    // Injected as MAX_ISO_SURFACES into the compute shader.
+    g_maxIsoSurfaces = 8
+
+    // Smooth normals from the density gradient instead of flat triangle normals.
+    g_highQualityNormals = true
+
+    // Injected as SHADOW_CASCADES into the fragment shader.
+    g_shadowCascades = 3
+    g_shadowMapSize  = 2048
+    // Nothing further away from the camera casts or receives shadows.
+    g_shadowDistance = 300.0
+
+    // Width and height of every layer of the terrain texture array.
+    g_terrainTextureSize = 256
+
+    // Injected as MAX_POINT_LIGHTS into the fragment shader.
+    g_maxPointLights = 8
+
+    // Injected as MAX_SSAO_SAMPLES into the SSAO shader.
+    g_maxSsaoSamples = 64
+
+    // Injected as BAKED_AO_RAYS into the compute shader. Rays per vertex for the occlusion
+    // from the density field. 0 disables it.
+    g_bakedAoRays = 12
 
 )
 
 const g_WindowTitle  = "First test to create Marching Cubes"
-var g_ShaderID uint32
 
-
-// Normal Camera
-var g_fovy      = mgl32.DegToRad(90.0)
-var g_aspect    = float32(g_WindowWidth)/g_WindowHeight
-var g_nearPlane = float32(0.1)
-var g_farPlane  = float32(2000.0)
+// The shader files are watched and recompiled, as soon as they change.
+var g_renderProgram        *WatchedProgram
+var g_marchingCubesProgram *WatchedProgram
+var g_shadowProgram        *WatchedProgram
+
+var g_shadowMaps     *CascadedShadowMaps
+var g_shadowsEnabled = true
+var g_shadowFilter   = PCFShadowFilter
+
+// Texture array with one layer per TerrainLayerNames.
+var g_terrainTextures uint32
+
+// Physically based shading into g_hdrFbo, followed by tone mapping. Otherwise the original look.
+var g_pbr      = true
+var g_exposure float32 = 1.0
+// Half float colour, so the lighting can go above 1.0 before it is tone mapped.
+var g_hdrFbo, g_hdrColorTex, g_hdrDepthTex uint32
+var g_tonemapProgram *WatchedProgram
+// Empty. The fullscreen triangle is created from gl_VertexID.
+var g_screenVertexArray uint32
+
+// The sun. g_light only defines its direction.
+var g_sunColor = mgl32.Vec3{1.0, 0.95, 0.85}.Mul(3.0)
+var g_pointLights []PointLight
+
+// Screen space ambient occlusion.
+var g_ssao          *SSAO
+var g_ssaoEnabled   = true
+// Shows the occlusion buffer instead of the scene.
+var g_ssaoDebug     = false
+// Uses the occlusion, that is baked into the vertices.
+var g_bakedAoEnabled = true
+
+// Renders one frame without a window into g_outputFbo and writes it to g_screenshotFile.
+var g_headless       = flag.Bool("headless", false, "render one frame without a window (EGL) and save it as PNG")
+var g_screenshotFile = flag.String("screenshot", "screenshot.png", "PNG file of the headless frame")
+// Renders all regression scenes headless and compares them to the golden images. See regression.go.
+var g_regression     = flag.Bool("regression", false, "render the regression scenes headless and compare them to the golden images")
+var g_goldenDir      = flag.String("golden", "", "directory of the golden images (created with -update-golden, default Golden/images in the source tree)")
+var g_updateGolden   = flag.Bool("update-golden", false, "write the regression renders as new golden images")
+var g_diffDir        = flag.String("diff", "regression_diff", "directory for the renders and diff images of failed scenes")
+// Extracts every density preset on the GPU and compares it against the CPU mesher. See meshtest.go.
+var g_meshTest       = flag.Bool("meshtest", false, "compare the GPU extraction of every density preset against the CPU reference mesher")
+// Compares caseToNumPolys and edgeConnectList against tables generated from the cube topology. See tablecheck.go.
+var g_checkTables    = flag.Bool("checktables", false, "compare the marching cubes tables against the generated ones")
+// Camera bookmarks and the path through them. See camerapath.go.
+var g_bookmarkFile    = flag.String("bookmarks", "bookmarks.json", "JSON file of the camera bookmarks")
+var g_segmentDuration = flag.Float64("segment-time", 3.0, "seconds of the camera path from one bookmark to the next")
+var g_pathFps         = flag.Float64("path-fps", 30.0, "frames per second of the camera path. Every frame advances the path by 1/fps")
+var g_sequenceDir     = flag.String("sequence", "", "render the camera path through all bookmarks headless into this directory")
+// The final image goes in here. 0 is the window.
+var g_outputFbo      uint32 = 0
+var g_outputColorTex uint32
+var g_outputDepthTex uint32
+// Saved after the next frame. F12.
+var g_takeScreenshot = false
+var g_screenshotCount = 0
+var g_normalsProgram   *WatchedProgram
+var g_ssaoProgram      *WatchedProgram
+var g_ssaoBlurProgram  *WatchedProgram
+var g_ssaoDebugProgram *WatchedProgram
+
+// Uniform errors happen every frame. So every distinct error is only printed once.
+var g_reportedErrors = make(map[string]bool)
+
+
+// Size of the window and all screen sized render targets. From g_config.
+var g_windowWidth, g_windowHeight int32
+
+// Number of small cubes per unit. From g_config. Larger units (i.e. 32x32x32 or 64x64x64)
+// only need fewer units (-units) for the same terrain.
+var g_cubeWidth, g_cubeHeight, g_cubeDepth int
+
+// Camera of the window. Projection from g_config.
+var g_camera *Camera
 
 var g_viewMatrix          mgl32.Mat4
 
 var g_light Object
 
-
-type Vertex struct {
-    Pos      mgl32.Vec4
-    Normal   mgl32.Vec4
+// Additional light without shadows. Only used for physically based shading.
+type PointLight struct {
+    // Pos and Color of the sphere are the position and colour of the light.
+    Object      Object
+    Intensity   float32
+    // The light fades out completely at this distance.
+    Radius      float32
 }
 
+
 type Triangle struct {
     Vertices []Vertex
 }
@@ -61,32 +163,51 @@ type MarchingCubeUnit struct {
     // Shows the outline (as wireframe) of a Marching-Cube-Unit (Box/Cube)
     BoxOutline          Object
     ShowOutline         bool
+    // The triangles of this unit have to be recalculated, i.e. because a terrain parameter changed.
+    Dirty               bool
+}
+
+// One iso-surface, that is extracted from the density field.
+// All iso-surfaces are calculated in the same pass but every one of them
+// is written into its own range of the position buffer and rendered with its own material.
+type IsoSurface struct {
+    IsoLevel            float32
+    Color               mgl32.Vec3
+    // 1.0 is opaque. Everything below is rendered with blending after all opaque surfaces.
+    Alpha               float32
+    // Triplanar terrain textures (by height and slope) instead of Color.
+    Textured            bool
+    // Physically based shading. Textured surfaces use the roughness of the texture layers instead.
+    Roughness           float32
+    Metallic            float32
+    // The range inside the position buffer. Updated after every marching cubes run.
+    TriangleOffset      int
+    TriangleCount       int
 }
 
 // This holds all the information, counters and buffers
 // to create and render the marching cubes, consisting of several
 // "units" (blocks that are dispatched to the GPU consecutively).
 type MarchingCubes struct {
-    // The shader used for calculating the marching cubes.
-    ShaderID                uint32
-    // This is the worst case, if every cube actually creates 5 triangles.
-    // This is not realistic, so optimize later!
-    TriangleCount           int
-    // This arraybuffer is is main handle to the calculated positions on the GPU.
-    PositionArrayBuffer     uint32
-    // This points to the vertex buffer (see g_positionBuffer) for rendering
-    PositionVertexBuffer    uint32
-    // The buffer, the first run of marching cubes writes the triangle count into, they like to generate.
-    TriangleLayoutSizesBuffer   uint32
+    // The buffers of the compute shader. All units and iso-surfaces share them.
+    *MarchingCubesBuffers
     // How many units we create.
     UnitCount               int
     // The instances of every Marching cube
     MarchingCubeUnits       []MarchingCubeUnit
+    // All iso-surfaces that are extracted from the same density field.
+    IsoSurfaces             []IsoSurface
 
 }
 
 var g_marchingCubes MarchingCubes
 
+// All terrain parameters of the density function. Uploaded as uniforms before dispatching.
+var g_parameters *ParameterRegistry
+// The parameter (and component for vec3) that is currently tweaked with the keyboard.
+var g_selectedParameter = 0
+var g_selectedComponent = 0
+
 
 var g_timeSum float32 = 0.0
 var g_lastCallTime float64 = 0.0
@@ -95,6 +216,9 @@ var g_fps float32 = 60.0
 
 var g_fillMode = 0
 
+// Index into DensityPresets.
+var g_densityPreset  = 0
+
 func init() {
     // GLFW event handling must run on the main OS thread
     runtime.LockOSThread()
@@ -103,8 +227,37 @@ func init() {
 
 func printHelp() {
     fmt.Println(
-        `Help yourself.`,
+        `Keys:
+  H              this help
+  Esc, Q         quit (Q only in orbit mode)
+  Tab            switch between orbit and fly camera
+  Mouse          orbit: drag to rotate, scroll for the distance
+                 fly: look around, scroll for the speed
+  W/A/S/D, Q/E   fly forward/left/back/right, down/up
+                 (faster with shift, slower with control)
+  B              bookmark the camera (saved to -bookmarks)
+  1-9            go to a bookmark
+  P              follow the path through all bookmarks, or stop
+  F1             filled, wireframe or points
+  F2             shadows on/off
+  F3             shadow filter
+  F4             physically based shading on/off
+  F5, F6         SSAO on/off, SSAO debug view
+  F7             baked ambient occlusion on/off
+  F8             next density preset
+  F12            screenshot
+  PageUp/Down    SSAO radius
+  Home/End       SSAO samples
+  Up/Down        move the light
+  Left/Right     select a terrain parameter
+  +/-            change it (faster with shift)
+
+Flags:`,
     )
+    flag.CommandLine.SetOutput(os.Stdout)
+    flag.PrintDefaults()
+    fmt.Println(`
+Meshes are exported without a window with the mcubes command (see 'mcubes mesh -h').`)
 }
 
 // Set OpenGL version, profile and compatibility
@@ -115,7 +268,7 @@ func initGraphicContext() (*glfw.Window, error) {
     glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
     glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True)
 
-    window, err := glfw.CreateWindow(g_WindowWidth, g_WindowHeight, g_WindowTitle, nil, nil)
+    window, err := glfw.CreateWindow(int(g_windowWidth), int(g_windowHeight), g_WindowTitle, nil, nil)
     if err != nil {
         return nil, err
     }
@@ -129,38 +282,240 @@ func initGraphicContext() (*glfw.Window, error) {
     return window, nil
 }
 
-func defineModelMatrix(shader uint32, pos, scale mgl32.Vec3) {
+// Takes over the settings, that are changed at runtime.
+func applyConfig() {
+    g_windowWidth, g_windowHeight = int32(g_config.WindowWidth), int32(g_config.WindowHeight)
+    g_cubeWidth, g_cubeHeight, g_cubeDepth = g_config.ChunkSize[0], g_config.ChunkSize[1], g_config.ChunkSize[2]
+    g_camera = NewCamera(mgl32.Vec3{0,8,15}, mgl32.Vec3{0,0,0}, mgl32.Vec3{0,1,0},
+        mgl32.DegToRad(float32(g_config.Fovy)), float32(g_windowWidth)/float32(g_windowHeight),
+        float32(g_config.NearPlane), float32(g_config.FarPlane))
+}
+
+func logOnce(err error) {
+    if err != nil && !g_reportedErrors[err.Error()] {
+        g_reportedErrors[err.Error()] = true
+        fmt.Println(err)
+    }
+}
+
+func defineModelMatrix(program *Program, pos, scale mgl32.Vec3) {
     matScale := mgl32.Scale3D(scale.X(), scale.Y(), scale.Z())
     matTrans := mgl32.Translate3D(pos.X(), pos.Y(), pos.Z())
     model := matTrans.Mul4(matScale)
-    modelUniform := gl.GetUniformLocation(shader, gl.Str("modelMat\x00"))
-    gl.UniformMatrix4fv(modelUniform, 1, false, &model[0])
+    logOnce(program.SetMat4("modelMat", model))
 }
 
-// Defines the Model-View-Projection matrices for the shader.
-func defineMatrices(shader uint32) {
-    projection := mgl32.Perspective(g_fovy, g_aspect, g_nearPlane, g_farPlane)
-    camera := mgl32.LookAtV(GetCameraLookAt())
+// The light is treated as a directional light, shining from its position onto the center of the terrain.
+func lightDirection() mgl32.Vec3 {
+    center := mgl32.Vec3{}
+    for _,unit := range g_marchingCubes.MarchingCubeUnits {
+        center = center.Add(unit.PositionOffset)
+    }
+    center = center.Mul(1.0/float32(len(g_marchingCubes.MarchingCubeUnits)))
+    center = center.Add(mgl32.Vec3{float32(g_cubeWidth)/2.0, float32(g_cubeHeight)/2.0, float32(g_cubeDepth)/2.0})
+
+    return g_light.Pos.Sub(center).Normalize()
+}
+
+func defineShadows(program *Program) {
+    logOnce(program.SetVec3("lightDirection", lightDirection()))
+    logOnce(program.SetVec3("cameraPos", g_camera.Pos))
+    logOnce(program.SetVec3("viewDirection", g_camera.Direction()))
+
+    logOnce(program.SetBool("shadowsEnabled", g_shadowsEnabled))
+    logOnce(program.SetInt("shadowFilter", int32(g_shadowFilter)))
+    logOnce(program.SetMat4s("lightViewProjectionMats", g_shadowMaps.LightViewProjections()))
+    logOnce(program.SetFloats("cascadeSplits", g_shadowMaps.Splits()))
 
-    viewProjection := projection.Mul4(camera);
-    cameraUniform := gl.GetUniformLocation(shader, gl.Str("viewProjectionMat\x00"))
-    gl.UniformMatrix4fv(cameraUniform, 1, false, &viewProjection[0])
+    depthUnits, momentsUnits := g_shadowMaps.BindTextures(0)
+    logOnce(program.SetInts("shadowDepthMaps", depthUnits))
+    logOnce(program.SetInts("shadowMomentsMaps", momentsUnits))
 }
 
-func renderObject(shader uint32, obj Object) {
+func defineLights(program *Program) {
+    logOnce(program.SetBool("pbr", g_pbr))
+    logOnce(program.SetVec3("sunColor", g_sunColor))
+
+    count := len(g_pointLights)
+    if count > g_maxPointLights {
+        count = g_maxPointLights
+    }
+    positions := make([]mgl32.Vec3, count)
+    colors    := make([]mgl32.Vec3, count)
+    radii     := make([]float32, count)
+    for i := 0; i < count; i++ {
+        light := g_pointLights[i]
+        positions[i] = light.Object.Pos
+        colors[i]    = light.Object.Color.Mul(light.Intensity)
+        radii[i]     = light.Radius
+    }
+    logOnce(program.SetInt("pointLightCount", int32(count)))
+    logOnce(program.SetVec3s("pointLightPositions", positions))
+    logOnce(program.SetVec3s("pointLightColors", colors))
+    logOnce(program.SetFloats("pointLightRadii", radii))
+}
+
+// The blurred occlusion comes after the terrain textures.
+func defineSSAO(program *Program) {
+    unit := int32(2*g_shadowCascades + 1)
+    gl.ActiveTexture(gl.TEXTURE0 + uint32(unit))
+    gl.BindTexture(gl.TEXTURE_2D, g_ssao.BlurTex)
+    gl.ActiveTexture(gl.TEXTURE0)
+    logOnce(program.SetBool("ssaoEnabled", g_ssaoEnabled))
+    logOnce(program.SetBool("bakedAoEnabled", g_bakedAoEnabled))
+    logOnce(program.SetInt("ssaoTexture", unit))
+}
+
+// Renders the normals and depth of the opaque iso-surfaces, calculates the occlusion from them and blurs it.
+func renderSSAO() {
+    if !g_ssaoEnabled {
+        return
+    }
+
+    polyMode := currentPolygonMode()
+    gl.PolygonMode(gl.FRONT_AND_BACK, gl.FILL)
+
+    projection := g_camera.ProjectionMatrix()
+    view := g_camera.ViewMatrix()
+
+    program := g_normalsProgram.Program
+    g_ssao.BeginGeometry()
+    gl.Enable(gl.DEPTH_TEST)
+    gl.UseProgram(program.ID)
+    logOnce(program.SetMat4("viewProjectionMat", projection.Mul4(view)))
+    logOnce(program.SetMat4("viewMat", view))
+    defineModelMatrix(program, mgl32.Vec3{0,0,0}, mgl32.Vec3{1,1,1})
+    gl.BindVertexArray(g_marchingCubes.PositionVertexBuffer)
+    for _,surface := range g_marchingCubes.IsoSurfaces {
+        if surface.Alpha >= 1.0 {
+            gl.DrawArrays(gl.TRIANGLES, int32(3*surface.TriangleOffset), int32(3*surface.TriangleCount))
+        }
+    }
+    gl.BindVertexArray(0)
+
+    gl.Disable(gl.DEPTH_TEST)
+
+    program = g_ssaoProgram.Program
+    gl.BindFramebuffer(gl.FRAMEBUFFER, g_ssao.AoFbo)
+    gl.UseProgram(program.ID)
+    gl.ActiveTexture(gl.TEXTURE0)
+    gl.BindTexture(gl.TEXTURE_2D, g_ssao.DepthTex)
+    gl.ActiveTexture(gl.TEXTURE1)
+    gl.BindTexture(gl.TEXTURE_2D, g_ssao.NormalTex)
+    gl.ActiveTexture(gl.TEXTURE0)
+    logOnce(program.SetInt("depthTexture", 0))
+    logOnce(program.SetInt("normalTexture", 1))
+    logOnce(program.SetMat4("projectionMat", projection))
+    logOnce(program.SetMat4("inverseProjectionMat", projection.Inv()))
+    logOnce(program.SetVec3s("ssaoKernel", g_ssao.Kernel))
+    logOnce(program.SetInt("sampleCount", int32(g_ssao.SampleCount())))
+    logOnce(program.SetFloat("radius", g_ssao.Radius))
+    renderFullscreen()
+
+    program = g_ssaoBlurProgram.Program
+    gl.BindFramebuffer(gl.FRAMEBUFFER, g_ssao.BlurFbo)
+    gl.UseProgram(program.ID)
+    gl.BindTexture(gl.TEXTURE_2D, g_ssao.AoTex)
+    logOnce(program.SetInt("aoTexture", 0))
+    renderFullscreen()
+
+    gl.UseProgram(0)
+    gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+    gl.Enable(gl.DEPTH_TEST)
+    gl.PolygonMode(gl.FRONT_AND_BACK, polyMode)
+}
+
+func renderSSAODebug() {
+    program := g_ssaoDebugProgram.Program
+
+    gl.BindFramebuffer(gl.FRAMEBUFFER, g_outputFbo)
+    gl.Viewport(0, 0, g_windowWidth, g_windowHeight)
+    gl.Disable(gl.DEPTH_TEST)
+
+    polyMode := currentPolygonMode()
+    gl.PolygonMode(gl.FRONT_AND_BACK, gl.FILL)
+
+    gl.UseProgram(program.ID)
+    gl.ActiveTexture(gl.TEXTURE0)
+    gl.BindTexture(gl.TEXTURE_2D, g_ssao.BlurTex)
+    logOnce(program.SetInt("aoTexture", 0))
+    renderFullscreen()
+
+    gl.UseProgram(0)
+    gl.PolygonMode(gl.FRONT_AND_BACK, polyMode)
+    gl.Enable(gl.DEPTH_TEST)
+}
+
+// One triangle covering the whole viewport. The vertex shader is fullscreen.vert.
+func renderFullscreen() {
+    gl.BindVertexArray(g_screenVertexArray)
+    gl.DrawArrays(gl.TRIANGLES, 0, 3)
+    gl.BindVertexArray(0)
+}
+
+// The texture unit comes right after the shadow maps.
+func defineTerrainTextures(program *Program) {
+    unit := int32(2*g_shadowCascades)
+    gl.ActiveTexture(gl.TEXTURE0 + uint32(unit))
+    gl.BindTexture(gl.TEXTURE_2D_ARRAY, g_terrainTextures)
+    gl.ActiveTexture(gl.TEXTURE0)
+    logOnce(program.SetInt("terrainTextures", unit))
+}
+
+// Renders the opaque iso-surfaces into every shadow cascade.
+// Transparent surfaces do not cast shadows.
+func renderShadowMaps() {
+    if !g_shadowsEnabled {
+        return
+    }
+
+    g_shadowMaps.Fit(g_camera.ViewMatrix(), g_camera.Fovy, g_camera.Aspect, g_camera.Near, lightDirection())
+
+    program := g_shadowProgram.Program
+    gl.UseProgram(program.ID)
+    defineModelMatrix(program, mgl32.Vec3{0,0,0}, mgl32.Vec3{1,1,1})
+
+    polyMode := currentPolygonMode()
+    gl.PolygonMode(gl.FRONT_AND_BACK, gl.FILL)
+    gl.Enable(gl.DEPTH_TEST)
+    gl.BindVertexArray(g_marchingCubes.PositionVertexBuffer)
+
+    for i,cascade := range g_shadowMaps.Cascades {
+        g_shadowMaps.BeginCascade(i)
+        logOnce(program.SetMat4("lightViewProjectionMat", cascade.LightViewProjection))
+
+        for _,surface := range g_marchingCubes.IsoSurfaces {
+            if surface.Alpha >= 1.0 {
+                gl.DrawArrays(gl.TRIANGLES, int32(3*surface.TriangleOffset), int32(3*surface.TriangleCount))
+            }
+        }
+    }
+
+    gl.BindVertexArray(0)
+    gl.PolygonMode(gl.FRONT_AND_BACK, polyMode)
+    gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+    gl.UseProgram(0)
+}
+
+// Defines the Model-View-Projection matrices for the shader.
+func defineMatrices(program *Program) {
+    logOnce(program.SetMat4("viewProjectionMat", g_camera.ViewProjectionMatrix()))
+}
+
+func renderObject(program *Program, obj Object) {
 
     // Model transformations are now encoded per object directly before rendering it!
-    defineModelMatrix(shader, obj.Pos, obj.Scale)
+    defineModelMatrix(program, obj.Pos, obj.Scale)
 
     gl.BindVertexArray(obj.Geo.VertexObject)
 
-    gl.Uniform3fv(gl.GetUniformLocation(shader, gl.Str("color\x00")), 1, &obj.Color[0])
-    gl.Uniform3fv(gl.GetUniformLocation(shader, gl.Str("light\x00")), 1, &g_light.Pos[0])
-    var isLighti int32 = 0
-    if obj.IsLight {
-        isLighti = 1
-    }
-    gl.Uniform1i(gl.GetUniformLocation(shader, gl.Str("isLight\x00")), isLighti)
+    logOnce(program.SetVec3("color", obj.Color))
+    logOnce(program.SetBool("isLight", obj.IsLight))
+    logOnce(program.SetBool("textured", false))
+    logOnce(program.SetBool("vertexMaterials", false))
+    logOnce(program.SetFloat("surfaceRoughness", 0.5))
+    logOnce(program.SetFloat("surfaceMetallic", 0.0))
+    logOnce(program.SetFloat("alpha", 1.0))
 
     gl.DrawArrays(gl.TRIANGLES, 0, obj.Geo.VertexCount)
 
@@ -168,168 +523,403 @@ func renderObject(shader uint32, obj Object) {
 
 }
 
-func renderPositionBuffer(shader uint32) {
+func renderIsoSurface(program *Program, surface IsoSurface) {
+
+    logOnce(program.SetVec3("color", surface.Color))
+    logOnce(program.SetFloat("alpha", surface.Alpha))
+    logOnce(program.SetBool("textured", surface.Textured))
+    logOnce(program.SetBool("vertexMaterials", true))
+    logOnce(program.SetFloat("surfaceRoughness", surface.Roughness))
+    logOnce(program.SetFloat("surfaceMetallic", surface.Metallic))
+
+    gl.DrawArrays(gl.TRIANGLES, int32(3*surface.TriangleOffset), int32(3*surface.TriangleCount));
+}
+
+func renderPositionBuffer(program *Program) {
 
-    defineModelMatrix(shader, mgl32.Vec3{0,0,0}, mgl32.Vec3{1,1,1})
+    defineModelMatrix(program, mgl32.Vec3{0,0,0}, mgl32.Vec3{1,1,1})
 
-    color := mgl32.Vec3{1,0,0}
-    gl.Uniform3fv(gl.GetUniformLocation(shader, gl.Str("color\x00")), 1, &color[0])
-    gl.Uniform3fv(gl.GetUniformLocation(shader, gl.Str("light\x00")), 1, &g_light.Pos[0])
-    var isLighti int32 = 0
-    gl.Uniform1i(gl.GetUniformLocation(shader, gl.Str("isLight\x00")), isLighti)
+    logOnce(program.SetBool("isLight", false))
 
     /* Vertex-Buffer zum Rendern der Positionen */
     gl.BindVertexArray (g_marchingCubes.PositionVertexBuffer);
-    gl.DrawArrays(gl.TRIANGLES, 0, int32(3*g_marchingCubes.TriangleCount));
+
+    // Opaque surfaces first, so the transparent ones can be blended on top.
+    for _,surface := range g_marchingCubes.IsoSurfaces {
+        if surface.Alpha >= 1.0 {
+            renderIsoSurface(program, surface)
+        }
+    }
+
+    gl.Enable(gl.BLEND)
+    gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+    gl.DepthMask(false)
+    for _,surface := range g_marchingCubes.IsoSurfaces {
+        if surface.Alpha < 1.0 {
+            renderIsoSurface(program, surface)
+        }
+    }
+    gl.DepthMask(true)
+    gl.Disable(gl.BLEND)
+
     gl.BindVertexArray(0)
 }
 
 
-func renderEverything(shader uint32) {
+func renderEverything(program *Program) {
 
-    gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
     gl.Enable(gl.DEPTH_TEST)
-    // Nice blueish background
-    gl.ClearColor(135.0/255.,206.0/255.,235.0/255., 1.0)
+    if g_pbr {
+        gl.BindFramebuffer(gl.FRAMEBUFFER, g_hdrFbo)
+        // The same blue, but in linear space.
+        gl.ClearColor(0.24, 0.62, 0.83, 1.0)
+    } else {
+        gl.BindFramebuffer(gl.FRAMEBUFFER, g_outputFbo)
+        // Nice blueish background
+        gl.ClearColor(135.0/255.,206.0/255.,235.0/255., 1.0)
+    }
 
     gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
-    gl.Viewport(0, 0, g_WindowWidth, g_WindowHeight)
+    gl.Viewport(0, 0, g_windowWidth, g_windowHeight)
 
-    gl.UseProgram(shader)
+    gl.UseProgram(program.ID)
 
-    defineMatrices(shader)
+    defineMatrices(program)
+    defineShadows(program)
+    defineTerrainTextures(program)
+    defineLights(program)
+    defineSSAO(program)
 
-    renderObject(shader, g_light)
+    renderObject(program, g_light)
+    if g_pbr {
+        for _,light := range g_pointLights {
+            renderObject(program, light.Object)
+        }
+    }
 
-    renderPositionBuffer(shader)
+    renderPositionBuffer(program)
 
-    var polyMode int32
-    gl.GetIntegerv(gl.POLYGON_MODE, &polyMode)
+    polyMode := currentPolygonMode()
     gl.PolygonMode(gl.FRONT_AND_BACK, gl.LINE)
     for i,_ := range g_marchingCubes.MarchingCubeUnits {
-        renderObject(shader, g_marchingCubes.MarchingCubeUnits[i].BoxOutline)
+        renderObject(program, g_marchingCubes.MarchingCubeUnits[i].BoxOutline)
     }
-    gl.PolygonMode(gl.FRONT_AND_BACK, uint32(polyMode))
+    gl.PolygonMode(gl.FRONT_AND_BACK, polyMode)
 
     gl.UseProgram(0)
 
+    if g_pbr {
+        renderTonemapping()
+    }
+    if g_ssaoEnabled && g_ssaoDebug {
+        renderSSAODebug()
+    }
+
 }
 
-func calculateAndRenderMarchingCubes(window *glfw.Window) {
+// Maps the HDR buffer to the screen.
+func renderTonemapping() {
+    program := g_tonemapProgram.Program
 
-    gl.UseProgram(g_marchingCubes.ShaderID)
+    gl.BindFramebuffer(gl.FRAMEBUFFER, g_outputFbo)
+    gl.Viewport(0, 0, g_windowWidth, g_windowHeight)
+    gl.Disable(gl.DEPTH_TEST)
 
-    // This will fill the buffer with the sizes, that we need memory for in the next run.
-    gl.Uniform1i(gl.GetUniformLocation(g_marchingCubes.ShaderID, gl.Str("calculateSizeOnly\x00")), 1)
-    gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, 3, g_marchingCubes.TriangleLayoutSizesBuffer);
+    polyMode := currentPolygonMode()
+    gl.PolygonMode(gl.FRONT_AND_BACK, gl.FILL)
 
-    cubeUnitSize := g_cubeWidth * g_cubeHeight * g_cubeDepth
+    gl.UseProgram(program.ID)
+    gl.ActiveTexture(gl.TEXTURE0)
+    gl.BindTexture(gl.TEXTURE_2D, g_hdrColorTex)
+    logOnce(program.SetInt("hdrTexture", 0))
+    logOnce(program.SetFloat("exposure", g_exposure))
+    renderFullscreen()
 
-    for i:=0; i < g_marchingCubes.UnitCount; i++ {
-        gl.Uniform1i(gl.GetUniformLocation(g_marchingCubes.ShaderID, gl.Str("cubeIndexOffset\x00")), int32(i * cubeUnitSize))
-        gl.Uniform3fv(gl.GetUniformLocation(g_marchingCubes.ShaderID, gl.Str("cubePositionOffset\x00")),1, &g_marchingCubes.MarchingCubeUnits[i].PositionOffset[0])
-        gl.DispatchCompute(1, 1, 1)
-    }
+    gl.UseProgram(0)
+    gl.PolygonMode(gl.FRONT_AND_BACK, polyMode)
+    gl.Enable(gl.DEPTH_TEST)
+}
 
-    gl.MemoryBarrier(gl.BUFFER_UPDATE_BARRIER_BIT)
+// The terrain is only recalculated, if at least one unit is dirty.
+// All units share the same (compacted) buffers, so all of them are recalculated together.
+func needsRecalculation() bool {
+    for _,unit := range g_marchingCubes.MarchingCubeUnits {
+        if unit.Dirty {
+            return true
+        }
+    }
+    return false
+}
 
-    layoutArraySize := g_marchingCubes.UnitCount * cubeUnitSize
+func calculateAndRenderMarchingCubes() {
 
-    // Add up all values, to determine the exact storage layout locations for each shader invocation
-    ptr := gl.MapBufferRange(gl.SHADER_STORAGE_BUFFER, 0, layoutArraySize*int(unsafe.Sizeof(int32(0))), gl.MAP_WRITE_BIT | gl.MAP_READ_BIT)
-    // This is really tricky. We get a pure C-Array pointer from glMapBufferRange, which is in our user address space.
-    // Unfortunately, we cannot use it directly in Go or convert it easily to a usable slice.
-    // So instead, we apply some magic (bit-shift-stuff) and work on C.int datatype directly. This should operate then directly
-    // on the underlaying C-Array in memory.
-    layoutSizes := (*[1 << 30]C.int)(unsafe.Pointer(ptr))[:layoutArraySize:layoutArraySize]
-    var lastSize int = int(layoutSizes[layoutArraySize-1])
-    var sum  C.int = 0
-    var sum2 C.int = layoutSizes[0]
-    for i := 1; i < layoutArraySize; i++ {
-        sum = layoutSizes[i]
-        layoutSizes[i] = sum2
-        sum2 += sum
+    if needsRecalculation() {
+        calculateMarchingCubes()
     }
-    layoutSizes[0] = 0
 
-    // This determines, how many triangles actually have to be rendered!
+    renderShadowMaps()
+    renderSSAO()
+
+    renderEverything(g_renderProgram.Program)
+
+}
+
+func calculateMarchingCubes() {
+
+    program := g_marchingCubesProgram.Program
+    gl.UseProgram(program.ID)
+
+    logOnce(g_parameters.Upload(program))
+    logOnce(program.SetInt("densityPreset", int32(g_densityPreset)))
+
     lastTriangleCount := g_marchingCubes.TriangleCount
-    g_marchingCubes.TriangleCount = int(layoutSizes[layoutArraySize-1]) + lastSize
+    logOnce(g_marchingCubes.Extract(program))
+
+    // Every unit has one block of cubes per iso-surface.
+    for u,_ := range g_marchingCubes.MarchingCubeUnits {
+        unit := &g_marchingCubes.MarchingCubeUnits[u]
+        unit.RenderTriangleCount = 0
+        for s,_ := range g_marchingCubes.IsoSurfaces {
+            first, end := g_marchingCubes.UnitTriangles(u, s)
+            unit.RenderTriangleCount += end - first
+        }
+    }
+
+    for i,_ := range g_marchingCubes.IsoSurfaces {
+        surface := &g_marchingCubes.IsoSurfaces[i]
+        first, end := g_marchingCubes.SurfaceTriangles(i)
+        surface.TriangleOffset = first
+        surface.TriangleCount = end - first
+    }
 
     if lastTriangleCount != g_marchingCubes.TriangleCount {
+        cubeCount := len(g_marchingCubes.IsoSurfaces) * g_marchingCubes.Grid.TotalCubeCount()
         fmt.Println("triangle count: ", g_marchingCubes.TriangleCount)
-        fmt.Println("triangles/cube: ", float32(g_marchingCubes.TriangleCount)/float32(layoutArraySize))
+        fmt.Println("triangles/cube: ", float32(g_marchingCubes.TriangleCount)/float32(cubeCount))
         fmt.Println("unit count:     ", g_marchingCubes.UnitCount)
+        for i,surface := range g_marchingCubes.IsoSurfaces {
+            fmt.Printf("iso-surface %v (level %.2f): %v triangles\n", i, surface.IsoLevel, surface.TriangleCount)
+        }
     }
 
-    gl.UnmapBuffer(gl.SHADER_STORAGE_BUFFER)
-
-    // This will actually create the triangle data seamless in the g_positionBuffer.
-    gl.Uniform1i(gl.GetUniformLocation(g_marchingCubes.ShaderID, gl.Str("calculateSizeOnly\x00")), 0)
-    gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, 2, g_marchingCubes.PositionArrayBuffer);
+    gl.UseProgram(0)
 
-    for i:=0; i < g_marchingCubes.UnitCount; i++ {
-        gl.Uniform1i(gl.GetUniformLocation(g_marchingCubes.ShaderID, gl.Str("cubeIndexOffset\x00")), int32(i * cubeUnitSize))
-        gl.Uniform3fv(gl.GetUniformLocation(g_marchingCubes.ShaderID, gl.Str("cubePositionOffset\x00")),1, &g_marchingCubes.MarchingCubeUnits[i].PositionOffset[0])
-        gl.DispatchCompute(1, 1, 1)
+    for i,_ := range g_marchingCubes.MarchingCubeUnits {
+        g_marchingCubes.MarchingCubeUnits[i].Dirty = false
     }
 
+}
 
-    gl.MemoryBarrier(gl.BUFFER_UPDATE_BARRIER_BIT)
-    gl.UseProgram(0)
-
+// GL_POLYGON_MODE returns two values (front and back). Querying it into a single int32 overwrites memory.
+func currentPolygonMode() uint32 {
+    var polyMode [2]int32
+    gl.GetIntegerv(gl.POLYGON_MODE, &polyMode[0])
+    return uint32(polyMode[0])
+}
 
-    renderEverything(g_ShaderID)
+// Filled, wireframe or points.
+func applyFillMode() {
+    switch (g_fillMode%3) {
+        case 0:
+            gl.PolygonMode(gl.FRONT_AND_BACK, gl.FILL)
+        case 1:
+            gl.PolygonMode(gl.FRONT_AND_BACK, gl.LINE)
+        case 2:
+            gl.PolygonMode(gl.FRONT_AND_BACK, gl.POINT)
+    }
+}
 
+// Switches the density function of the compute shader.
+func setDensityPreset(preset int) {
+    if preset == g_densityPreset {
+        return
+    }
+    g_densityPreset = preset
+    fmt.Println("density preset:", DensityPresets[preset])
+    for i,_ := range g_marchingCubes.MarchingCubeUnits {
+        g_marchingCubes.MarchingCubeUnits[i].Dirty = true
+    }
 }
 
 // Callback method for a keyboard press
 func cbKeyboard(window *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
 
+    // The fly camera moves while the keys are held.
+    g_camera.UpdateKey(key, action, mods)
+
     // All changes come VERY easy now.
     if action == glfw.Press {
         switch key {
-            // Close the Simulation.
-            case glfw.KeyEscape, glfw.KeyQ:
+            // Close the Simulation. Q moves the fly camera down.
+            case glfw.KeyEscape:
                 window.SetShouldClose(true)
+            case glfw.KeyQ:
+                if g_camera.Mode() == OrbitMode {
+                    window.SetShouldClose(true)
+                }
+            case glfw.KeyTab:
+                toggleCameraMode(window)
+            case glfw.KeyB:
+                addBookmark()
+            case glfw.Key1, glfw.Key2, glfw.Key3, glfw.Key4, glfw.Key5, glfw.Key6, glfw.Key7, glfw.Key8, glfw.Key9:
+                goToBookmark(int(key - glfw.Key1))
+            case glfw.KeyP:
+                toggleCameraPath()
             case glfw.KeyH:
                 printHelp()
             case glfw.KeySpace:
             case glfw.KeyF1:
                 g_fillMode += 1
-                switch (g_fillMode%3) {
-                    case 0:
-                        gl.PolygonMode(gl.FRONT_AND_BACK, gl.FILL)
-                    case 1:
-                        gl.PolygonMode(gl.FRONT_AND_BACK, gl.LINE)
-                    case 2:
-                        gl.PolygonMode(gl.FRONT_AND_BACK, gl.POINT)
-                }
+                applyFillMode()
             case glfw.KeyF2:
-
+                g_shadowsEnabled = !g_shadowsEnabled
             case glfw.KeyF3:
+                g_shadowFilter = (g_shadowFilter + 1) % 2
+                fmt.Println("shadow filter:", g_shadowFilter)
+            case glfw.KeyF4:
+                g_pbr = !g_pbr
+            case glfw.KeyF5:
+                g_ssaoEnabled = !g_ssaoEnabled
+            case glfw.KeyF6:
+                g_ssaoDebug = !g_ssaoDebug
+            case glfw.KeyF7:
+                g_bakedAoEnabled = !g_bakedAoEnabled
+            case glfw.KeyF8:
+                setDensityPreset((g_densityPreset + 1) % len(DensityPresets))
+            case glfw.KeyF12:
+                g_takeScreenshot = true
+            case glfw.KeyPageUp:
+                g_ssao.Radius *= 1.25
+                fmt.Println("ssao radius:", g_ssao.Radius)
+            case glfw.KeyPageDown:
+                g_ssao.Radius /= 1.25
+                fmt.Println("ssao radius:", g_ssao.Radius)
+            case glfw.KeyHome:
+                if g_ssao.SampleCount() < g_maxSsaoSamples {
+                    g_ssao.SetSampleCount(g_ssao.SampleCount() + 4)
+                }
+                fmt.Println("ssao samples:", g_ssao.SampleCount())
+            case glfw.KeyEnd:
+                if g_ssao.SampleCount() > 4 {
+                    g_ssao.SetSampleCount(g_ssao.SampleCount() - 4)
+                }
+                fmt.Println("ssao samples:", g_ssao.SampleCount())
             case glfw.KeyUp:
                 g_light.Pos = g_light.Pos.Add(mgl32.Vec3{0,1.0,0})
             case glfw.KeyDown:
                 g_light.Pos = g_light.Pos.Add(mgl32.Vec3{0,-1.0,0})
             case glfw.KeyLeft:
+                selectParameter(-1)
             case glfw.KeyRight:
+                selectParameter(1)
+        }
+    }
+
+    // Terrain parameters are tweaked with +/-. Holding shift changes them faster.
+    if action == glfw.Press || action == glfw.Repeat {
+        var steps float32 = 1.0
+        if mods&glfw.ModShift != 0 {
+            steps = 10.0
+        }
+        switch key {
+            case glfw.KeyEqual, glfw.KeyKPAdd:
+                tweakSelectedParameter(steps)
+            case glfw.KeyMinus, glfw.KeyKPSubtract:
+                tweakSelectedParameter(-steps)
+        }
+    }
+
+}
+
+// The fly camera looks around with every mouse movement, so it captures the cursor.
+func toggleCameraMode(window *glfw.Window) {
+    if g_camera.Mode() == OrbitMode {
+        g_camera.SetMode(FlyMode)
+        window.SetInputMode(glfw.CursorMode, glfw.CursorDisabled)
+    } else {
+        g_camera.SetMode(OrbitMode)
+        window.SetInputMode(glfw.CursorMode, glfw.CursorNormal)
+    }
+    fmt.Println("camera:", g_camera.Mode())
+}
+
+// Walks through all parameters and every component of vec3 parameters.
+func selectParameter(direction int) {
+    parameters := g_parameters.Parameters()
+
+    g_selectedComponent += direction
+    if g_selectedComponent >= parameters[g_selectedParameter].Components() {
+        g_selectedParameter = (g_selectedParameter + 1) % len(parameters)
+        g_selectedComponent = 0
+    }
+    if g_selectedComponent < 0 {
+        g_selectedParameter = (g_selectedParameter + len(parameters) - 1) % len(parameters)
+        g_selectedComponent = parameters[g_selectedParameter].Components()-1
+    }
+
+    p := parameters[g_selectedParameter]
+    if p.Components() > 1 {
+        fmt.Printf("selected: %v[%v]\n", p, g_selectedComponent)
+    } else {
+        fmt.Printf("selected: %v\n", p)
+    }
+}
+
+func tweakSelectedParameter(steps float32) {
+    p := g_parameters.Parameters()[g_selectedParameter]
+    g_parameters.Nudge(p, g_selectedComponent, steps)
+    fmt.Println(p)
+}
+
+// Changing one of the terrain parameters triggers a recalculation of the terrain.
+func declareTerrainParameters() *ParameterRegistry {
+    parameters := DeclareTerrainParameters()
+
+    // The density function is global, so every unit is affected by every parameter.
+    parameters.OnChange = func(p *Parameter) {
+        for i,_ := range g_marchingCubes.MarchingCubeUnits {
+            g_marchingCubes.MarchingCubeUnits[i].Dirty = true
         }
     }
 
+    return parameters
+}
+
+// The framebuffer size is in pixels. On HiDPI screens, that is more than the window size.
+func cbFramebufferSize(window *glfw.Window, width, height int) {
+    resizeScreen(int32(width), int32(height))
+}
+
+// Everything with the size of the screen: The viewport, the aspect ratio and the render targets.
+// A minimized window has size 0, then everything stays as it is.
+func resizeScreen(width, height int32) {
+    if width <= 0 || height <= 0 || (width == g_windowWidth && height == g_windowHeight) {
+        return
+    }
+    g_windowWidth, g_windowHeight = width, height
+    g_camera.Aspect = float32(width)/float32(height)
+
+    DeleteFbo(&g_hdrFbo, &g_hdrColorTex, &g_hdrDepthTex)
+    CreateFboWithFormat(&g_hdrFbo, &g_hdrColorTex, &g_hdrDepthTex, width, height, gl.RGBA16F, false)
+    g_ssao.Resize(width, height)
+    // 0 is the window, which resizes itself.
+    if g_outputFbo != 0 {
+        DeleteFbo(&g_outputFbo, &g_outputColorTex, &g_outputDepthTex)
+        CreateFbo(&g_outputFbo, &g_outputColorTex, &g_outputDepthTex, width, height, false)
+    }
 }
 
 // see: https://github.com/go-gl/glfw/blob/master/v3.2/glfw/input.go
 func cbMouseScroll(window *glfw.Window, xpos, ypos float64) {
-    UpdateMouseScroll(xpos, ypos)
+    g_camera.UpdateMouseScroll(xpos, ypos)
 }
 
 func cbMouseButton(window *glfw.Window, button glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
-    UpdateMouseButton(button, action, mods)
+    g_camera.UpdateMouseButton(button, action, mods)
 }
 
 func cbCursorPos(window *glfw.Window, xpos, ypos float64) {
-    UpdateCursorPos(xpos, ypos)
+    g_camera.UpdateCursorPos(xpos, ypos)
 }
 
 
@@ -339,6 +929,7 @@ func registerCallBacks (window *glfw.Window) {
     window.SetScrollCallback(cbMouseScroll)
     window.SetMouseButtonCallback(cbMouseButton)
     window.SetCursorPosCallback(cbCursorPos)
+    window.SetFramebufferSizeCallback(cbFramebufferSize)
 }
 
 
@@ -360,18 +951,94 @@ func displayFPS(window *glfw.Window) {
 
 }
 
+// Go constants and settings, the compute shader depends on. So they can not get out of sync.
+func marchingCubesDefines() ShaderDefines {
+    return ShaderDefines {
+        "WORK_GROUP_SIZE_X":    g_workGroupSizeX,
+        "WORK_GROUP_SIZE_Y":    g_workGroupSizeY,
+        "WORK_GROUP_SIZE_Z":    g_workGroupSizeZ,
+        "CHUNK_SIZE_X":         g_cubeWidth,
+        "CHUNK_SIZE_Y":         g_cubeHeight,
+        "CHUNK_SIZE_Z":         g_cubeDepth,
+        "MAX_ISO_SURFACES":     g_maxIsoSurfaces,
+        "HIGH_QUALITY_NORMALS": g_highQualityNormals,
+        "BAKED_AO_RAYS":        g_bakedAoRays,
+    }
+}
+
+// Swaps in the recompiled programs of changed shader files.
+func reloadChangedShaders() {
+    g_renderProgram.Update()
+    g_shadowProgram.Update()
+    g_tonemapProgram.Update()
+    g_normalsProgram.Update()
+    g_ssaoProgram.Update()
+    g_ssaoBlurProgram.Update()
+    g_ssaoDebugProgram.Update()
+    if g_marchingCubesProgram.Update() {
+        // The density function might have changed.
+        for i,_ := range g_marchingCubes.MarchingCubeUnits {
+            g_marchingCubes.MarchingCubeUnits[i].Dirty = true
+        }
+    }
+}
+
+// Writes the current content of the output framebuffer.
+func saveScreenshot(fileName string) error {
+    gl.Finish()
+    return SavePNG(fileName, ReadFramebuffer(g_outputFbo, g_windowWidth, g_windowHeight))
+}
+
+// Calculates the terrain and renders exactly one frame, then saves it.
+func renderHeadless() error {
+    calculateAndRenderMarchingCubes()
+    if err := saveScreenshot(*g_screenshotFile); err != nil {
+        return err
+    }
+    fmt.Println("saved", *g_screenshotFile)
+    return nil
+}
+
 // Mainloop for graphics updates and object animation
 func mainLoop (window *glfw.Window) {
 
     registerCallBacks(window)
-    glfw.SwapInterval(0)
+    // The callback only reports changes. On HiDPI screens, the framebuffer is larger than the configured size from the start.
+    width, height := window.GetFramebufferSize()
+    resizeScreen(int32(width), int32(height))
+
+    if g_config.VSync {
+        glfw.SwapInterval(1)
+    } else {
+        glfw.SwapInterval(0)
+    }
 
+    lastFrameTime := glfw.GetTime()
     for !window.ShouldClose() {
 
         displayFPS(window)
 
+        // Movement depends on the frame time, not on the frame rate. Camera paths have a fixed time step.
+        now := glfw.GetTime()
+        g_camera.Update(float32(now - lastFrameTime))
+        lastFrameTime = now
+        followCameraPath()
+
+        reloadChangedShaders()
+
         // This actually renders everything.
-        calculateAndRenderMarchingCubes(window)
+        calculateAndRenderMarchingCubes()
+
+        if g_takeScreenshot {
+            g_takeScreenshot = false
+            fileName := fmt.Sprintf("screenshot_%03d.png", g_screenshotCount)
+            g_screenshotCount += 1
+            if err := saveScreenshot(fileName); err != nil {
+                fmt.Println(err)
+            } else {
+                fmt.Println("saved", fileName)
+            }
+        }
 
         window.SwapBuffers()
         glfw.PollEvents()
@@ -381,150 +1048,152 @@ func mainLoop (window *glfw.Window) {
 
 }
 
-func createMarchingCubeConstBuffers(marchingCubesShaderID uint32) {
-
-    caseToNumPolys := []int32{0, 1, 1, 2, 1, 2, 2, 3,  1, 2, 2, 3, 2, 3, 3, 2,  1, 2, 2, 3, 2, 3, 3, 4,  2, 3, 3, 4, 3, 4, 4, 3,
-                               1, 2, 2, 3, 2, 3, 3, 4,  2, 3, 3, 4, 3, 4, 4, 3,  2, 3, 3, 2, 3, 4, 4, 3,  3, 4, 4, 3, 4, 5, 5, 2,
-                               1, 2, 2, 3, 2, 3, 3, 4,  2, 3, 3, 4, 3, 4, 4, 3,  2, 3, 3, 4, 3, 4, 4, 5,  3, 4, 4, 5, 4, 5, 5, 4,
-                               2, 3, 3, 4, 3, 4, 2, 3,  3, 4, 4, 5, 4, 5, 3, 2,  3, 4, 4, 3, 4, 5, 3, 2,  4, 5, 5, 4, 5, 2, 4, 1,
-                               1, 2, 2, 3, 2, 3, 3, 4,  2, 3, 3, 4, 3, 4, 4, 3,  2, 3, 3, 4, 3, 4, 4, 5,  3, 2, 4, 3, 4, 3, 5, 2,
-                               2, 3, 3, 4, 3, 4, 4, 5,  3, 4, 4, 5, 4, 5, 5, 4,  3, 4, 4, 3, 4, 5, 5, 4,  4, 3, 5, 2, 5, 4, 2, 1,
-                               2, 3, 3, 4, 3, 4, 4, 5,  3, 4, 4, 5, 2, 3, 3, 2,  3, 4, 4, 5, 4, 5, 5, 2,  4, 3, 5, 4, 3, 2, 4, 1,
-                               3, 4, 4, 5, 4, 5, 3, 4,  4, 5, 5, 2, 3, 4, 2, 1,  2, 3, 3, 2, 3, 4, 2, 1,  3, 2, 4, 1, 2, 1, 1, 0}
-
-    var caseABO uint32 = 0
-    gl.GenBuffers    (1, &caseABO);
-    gl.BindBuffer    (gl.ARRAY_BUFFER, caseABO);
-    gl.BufferData    (gl.ARRAY_BUFFER, len(caseToNumPolys)*int(unsafe.Sizeof(int32(0))), gl.Ptr(caseToNumPolys), gl.STATIC_READ);
-
-    gl.UseProgram(marchingCubesShaderID)
-    gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, 0, caseABO);
-    gl.UseProgram(0)
-
-    // List of 256 * 5 * vec3()
-    //edgeConnectList := [][]int32{{-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {0,8,3,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {0,1,9,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {1,8,3,9,8,1,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {1,2,10,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {0,8,3,1,2,10,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {9,2,10,0,2,9,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {2,8,3,2,10,8,10,9,8,-1,-1,-1,-1,-1,-1}, {3,11,2,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {0,11,2,8,11,0,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {1,9,0,2,3,11,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {1,11,2,1,9,11,9,8,11,-1,-1,-1,-1,-1,-1}, {3,10,1,11,10,3,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {0,10,1,0,8,10,8,11,10,-1,-1,-1,-1,-1,-1}, {3,9,0,3,11,9,11,10,9,-1,-1,-1,-1,-1,-1}, {9,8,10,10,8,11,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {4,7,8,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {4,3,0,7,3,4,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {0,1,9,8,4,7,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {4,1,9,4,7,1,7,3,1,-1,-1,-1,-1,-1,-1}, {1,2,10,8,4,7,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {3,4,7,3,0,4,1,2,10,-1,-1,-1,-1,-1,-1}, {9,2,10,9,0,2,8,4,7,-1,-1,-1,-1,-1,-1}, {2,10,9,2,9,7,2,7,3,7,9,4,-1,-1,-1}, {8,4,7,3,11,2,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {11,4,7,11,2,4,2,0,4,-1,-1,-1,-1,-1,-1}, {9,0,1,8,4,7,2,3,11,-1,-1,-1,-1,-1,-1}, {4,7,11,9,4,11,9,11,2,9,2,1,-1,-1,-1}, {3,10,1,3,11,10,7,8,4,-1,-1,-1,-1,-1,-1}, {1,11,10,1,4,11,1,0,4,7,11,4,-1,-1,-1}, {4,7,8,9,0,11,9,11,10,11,0,3,-1,-1,-1}, {4,7,11,4,11,9,9,11,10,-1,-1,-1,-1,-1,-1}, {9,5,4,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {9,5,4,0,8,3,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {0,5,4,1,5,0,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {8,5,4,8,3,5,3,1,5,-1,-1,-1,-1,-1,-1}, {1,2,10,9,5,4,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {3,0,8,1,2,10,4,9,5,-1,-1,-1,-1,-1,-1}, {5,2,10,5,4,2,4,0,2,-1,-1,-1,-1,-1,-1}, {2,10,5,3,2,5,3,5,4,3,4,8,-1,-1,-1}, {9,5,4,2,3,11,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {0,11,2,0,8,11,4,9,5,-1,-1,-1,-1,-1,-1}, {0,5,4,0,1,5,2,3,11,-1,-1,-1,-1,-1,-1}, {2,1,5,2,5,8,2,8,11,4,8,5,-1,-1,-1}, {10,3,11,10,1,3,9,5,4,-1,-1,-1,-1,-1,-1}, {4,9,5,0,8,1,8,10,1,8,11,10,-1,-1,-1}, {5,4,0,5,0,11,5,11,10,11,0,3,-1,-1,-1}, {5,4,8,5,8,10,10,8,11,-1,-1,-1,-1,-1,-1}, {9,7,8,5,7,9,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {9,3,0,9,5,3,5,7,3,-1,-1,-1,-1,-1,-1}, {0,7,8,0,1,7,1,5,7,-1,-1,-1,-1,-1,-1}, {1,5,3,3,5,7,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {9,7,8,9,5,7,10,1,2,-1,-1,-1,-1,-1,-1}, {10,1,2,9,5,0,5,3,0,5,7,3,-1,-1,-1}, {8,0,2,8,2,5,8,5,7,10,5,2,-1,-1,-1}, {2,10,5,2,5,3,3,5,7,-1,-1,-1,-1,-1,-1}, {7,9,5,7,8,9,3,11,2,-1,-1,-1,-1,-1,-1}, {9,5,7,9,7,2,9,2,0,2,7,11,-1,-1,-1}, {2,3,11,0,1,8,1,7,8,1,5,7,-1,-1,-1}, {11,2,1,11,1,7,7,1,5,-1,-1,-1,-1,-1,-1}, {9,5,8,8,5,7,10,1,3,10,3,11,-1,-1,-1}, {5,7,0,5,0,9,7,11,0,1,0,10,11,10,0}, {11,10,0,11,0,3,10,5,0,8,0,7,5,7,0}, {11,10,5,7,11,5,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {10,6,5,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {0,8,3,5,10,6,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {9,0,1,5,10,6,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {1,8,3,1,9,8,5,10,6,-1,-1,-1,-1,-1,-1}, {1,6,5,2,6,1,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {1,6,5,1,2,6,3,0,8,-1,-1,-1,-1,-1,-1}, {9,6,5,9,0,6,0,2,6,-1,-1,-1,-1,-1,-1}, {5,9,8,5,8,2,5,2,6,3,2,8,-1,-1,-1}, {2,3,11,10,6,5,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {11,0,8,11,2,0,10,6,5,-1,-1,-1,-1,-1,-1}, {0,1,9,2,3,11,5,10,6,-1,-1,-1,-1,-1,-1}, {5,10,6,1,9,2,9,11,2,9,8,11,-1,-1,-1}, {6,3,11,6,5,3,5,1,3,-1,-1,-1,-1,-1,-1}, {0,8,11,0,11,5,0,5,1,5,11,6,-1,-1,-1}, {3,11,6,0,3,6,0,6,5,0,5,9,-1,-1,-1}, {6,5,9,6,9,11,11,9,8,-1,-1,-1,-1,-1,-1}, {5,10,6,4,7,8,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {4,3,0,4,7,3,6,5,10,-1,-1,-1,-1,-1,-1}, {1,9,0,5,10,6,8,4,7,-1,-1,-1,-1,-1,-1}, {10,6,5,1,9,7,1,7,3,7,9,4,-1,-1,-1}, {6,1,2,6,5,1,4,7,8,-1,-1,-1,-1,-1,-1}, {1,2,5,5,2,6,3,0,4,3,4,7,-1,-1,-1}, {8,4,7,9,0,5,0,6,5,0,2,6,-1,-1,-1}, {7,3,9,7,9,4,3,2,9,5,9,6,2,6,9}, {3,11,2,7,8,4,10,6,5,-1,-1,-1,-1,-1,-1}, {5,10,6,4,7,2,4,2,0,2,7,11,-1,-1,-1}, {0,1,9,4,7,8,2,3,11,5,10,6,-1,-1,-1}, {9,2,1,9,11,2,9,4,11,7,11,4,5,10,6}, {8,4,7,3,11,5,3,5,1,5,11,6,-1,-1,-1}, {5,1,11,5,11,6,1,0,11,7,11,4,0,4,11}, {0,5,9,0,6,5,0,3,6,11,6,3,8,4,7}, {6,5,9,6,9,11,4,7,9,7,11,9,-1,-1,-1}, {10,4,9,6,4,10,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {4,10,6,4,9,10,0,8,3,-1,-1,-1,-1,-1,-1}, {10,0,1,10,6,0,6,4,0,-1,-1,-1,-1,-1,-1}, {8,3,1,8,1,6,8,6,4,6,1,10,-1,-1,-1}, {1,4,9,1,2,4,2,6,4,-1,-1,-1,-1,-1,-1}, {3,0,8,1,2,9,2,4,9,2,6,4,-1,-1,-1}, {0,2,4,4,2,6,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {8,3,2,8,2,4,4,2,6,-1,-1,-1,-1,-1,-1}, {10,4,9,10,6,4,11,2,3,-1,-1,-1,-1,-1,-1}, {0,8,2,2,8,11,4,9,10,4,10,6,-1,-1,-1}, {3,11,2,0,1,6,0,6,4,6,1,10,-1,-1,-1}, {6,4,1,6,1,10,4,8,1,2,1,11,8,11,1}, {9,6,4,9,3,6,9,1,3,11,6,3,-1,-1,-1}, {8,11,1,8,1,0,11,6,1,9,1,4,6,4,1}, {3,11,6,3,6,0,0,6,4,-1,-1,-1,-1,-1,-1}, {6,4,8,11,6,8,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {7,10,6,7,8,10,8,9,10,-1,-1,-1,-1,-1,-1}, {0,7,3,0,10,7,0,9,10,6,7,10,-1,-1,-1}, {10,6,7,1,10,7,1,7,8,1,8,0,-1,-1,-1}, {10,6,7,10,7,1,1,7,3,-1,-1,-1,-1,-1,-1}, {1,2,6,1,6,8,1,8,9,8,6,7,-1,-1,-1}, {2,6,9,2,9,1,6,7,9,0,9,3,7,3,9}, {7,8,0,7,0,6,6,0,2,-1,-1,-1,-1,-1,-1}, {7,3,2,6,7,2,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {2,3,11,10,6,8,10,8,9,8,6,7,-1,-1,-1}, {2,0,7,2,7,11,0,9,7,6,7,10,9,10,7}, {1,8,0,1,7,8,1,10,7,6,7,10,2,3,11}, {11,2,1,11,1,7,10,6,1,6,7,1,-1,-1,-1}, {8,9,6,8,6,7,9,1,6,11,6,3,1,3,6}, {0,9,1,11,6,7,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {7,8,0,7,0,6,3,11,0,11,6,0,-1,-1,-1}, {7,11,6,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {7,6,11,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {3,0,8,11,7,6,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {0,1,9,11,7,6,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {8,1,9,8,3,1,11,7,6,-1,-1,-1,-1,-1,-1}, {10,1,2,6,11,7,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {1,2,10,3,0,8,6,11,7,-1,-1,-1,-1,-1,-1}, {2,9,0,2,10,9,6,11,7,-1,-1,-1,-1,-1,-1}, {6,11,7,2,10,3,10,8,3,10,9,8,-1,-1,-1}, {7,2,3,6,2,7,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {7,0,8,7,6,0,6,2,0,-1,-1,-1,-1,-1,-1}, {2,7,6,2,3,7,0,1,9,-1,-1,-1,-1,-1,-1}, {1,6,2,1,8,6,1,9,8,8,7,6,-1,-1,-1}, {10,7,6,10,1,7,1,3,7,-1,-1,-1,-1,-1,-1}, {10,7,6,1,7,10,1,8,7,1,0,8,-1,-1,-1}, {0,3,7,0,7,10,0,10,9,6,10,7,-1,-1,-1}, {7,6,10,7,10,8,8,10,9,-1,-1,-1,-1,-1,-1}, {6,8,4,11,8,6,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {3,6,11,3,0,6,0,4,6,-1,-1,-1,-1,-1,-1}, {8,6,11,8,4,6,9,0,1,-1,-1,-1,-1,-1,-1}, {9,4,6,9,6,3,9,3,1,11,3,6,-1,-1,-1}, {6,8,4,6,11,8,2,10,1,-1,-1,-1,-1,-1,-1}, {1,2,10,3,0,11,0,6,11,0,4,6,-1,-1,-1}, {4,11,8,4,6,11,0,2,9,2,10,9,-1,-1,-1}, {10,9,3,10,3,2,9,4,3,11,3,6,4,6,3}, {8,2,3,8,4,2,4,6,2,-1,-1,-1,-1,-1,-1}, {0,4,2,4,6,2,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {1,9,0,2,3,4,2,4,6,4,3,8,-1,-1,-1}, {1,9,4,1,4,2,2,4,6,-1,-1,-1,-1,-1,-1}, {8,1,3,8,6,1,8,4,6,6,10,1,-1,-1,-1}, {10,1,0,10,0,6,6,0,4,-1,-1,-1,-1,-1,-1}, {4,6,3,4,3,8,6,10,3,0,3,9,10,9,3}, {10,9,4,6,10,4,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {4,9,5,7,6,11,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {0,8,3,4,9,5,11,7,6,-1,-1,-1,-1,-1,-1}, {5,0,1,5,4,0,7,6,11,-1,-1,-1,-1,-1,-1}, {11,7,6,8,3,4,3,5,4,3,1,5,-1,-1,-1}, {9,5,4,10,1,2,7,6,11,-1,-1,-1,-1,-1,-1}, {6,11,7,1,2,10,0,8,3,4,9,5,-1,-1,-1}, {7,6,11,5,4,10,4,2,10,4,0,2,-1,-1,-1}, {3,4,8,3,5,4,3,2,5,10,5,2,11,7,6}, {7,2,3,7,6,2,5,4,9,-1,-1,-1,-1,-1,-1}, {9,5,4,0,8,6,0,6,2,6,8,7,-1,-1,-1}, {3,6,2,3,7,6,1,5,0,5,4,0,-1,-1,-1}, {6,2,8,6,8,7,2,1,8,4,8,5,1,5,8}, {9,5,4,10,1,6,1,7,6,1,3,7,-1,-1,-1}, {1,6,10,1,7,6,1,0,7,8,7,0,9,5,4}, {4,0,10,4,10,5,0,3,10,6,10,7,3,7,10}, {7,6,10,7,10,8,5,4,10,4,8,10,-1,-1,-1}, {6,9,5,6,11,9,11,8,9,-1,-1,-1,-1,-1,-1}, {3,6,11,0,6,3,0,5,6,0,9,5,-1,-1,-1}, {0,11,8,0,5,11,0,1,5,5,6,11,-1,-1,-1}, {6,11,3,6,3,5,5,3,1,-1,-1,-1,-1,-1,-1}, {1,2,10,9,5,11,9,11,8,11,5,6,-1,-1,-1}, {0,11,3,0,6,11,0,9,6,5,6,9,1,2,10}, {11,8,5,11,5,6,8,0,5,10,5,2,0,2,5}, {6,11,3,6,3,5,2,10,3,10,5,3,-1,-1,-1}, {5,8,9,5,2,8,5,6,2,3,8,2,-1,-1,-1}, {9,5,6,9,6,0,0,6,2,-1,-1,-1,-1,-1,-1}, {1,5,8,1,8,0,5,6,8,3,8,2,6,2,8}, {1,5,6,2,1,6,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {1,3,6,1,6,10,3,8,6,5,6,9,8,9,6}, {10,1,0,10,0,6,9,5,0,5,6,0,-1,-1,-1}, {0,3,8,5,6,10,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {10,5,6,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {11,5,10,7,5,11,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {11,5,10,11,7,5,8,3,0,-1,-1,-1,-1,-1,-1}, {5,11,7,5,10,11,1,9,0,-1,-1,-1,-1,-1,-1}, {10,7,5,10,11,7,9,8,1,8,3,1,-1,-1,-1}, {11,1,2,11,7,1,7,5,1,-1,-1,-1,-1,-1,-1}, {0,8,3,1,2,7,1,7,5,7,2,11,-1,-1,-1}, {9,7,5,9,2,7,9,0,2,2,11,7,-1,-1,-1}, {7,5,2,7,2,11,5,9,2,3,2,8,9,8,2}, {2,5,10,2,3,5,3,7,5,-1,-1,-1,-1,-1,-1}, {8,2,0,8,5,2,8,7,5,10,2,5,-1,-1,-1}, {9,0,1,5,10,3,5,3,7,3,10,2,-1,-1,-1}, {9,8,2,9,2,1,8,7,2,10,2,5,7,5,2}, {1,3,5,3,7,5,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {0,8,7,0,7,1,1,7,5,-1,-1,-1,-1,-1,-1}, {9,0,3,9,3,5,5,3,7,-1,-1,-1,-1,-1,-1}, {9,8,7,5,9,7,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {5,8,4,5,10,8,10,11,8,-1,-1,-1,-1,-1,-1}, {5,0,4,5,11,0,5,10,11,11,3,0,-1,-1,-1}, {0,1,9,8,4,10,8,10,11,10,4,5,-1,-1,-1}, {10,11,4,10,4,5,11,3,4,9,4,1,3,1,4}, {2,5,1,2,8,5,2,11,8,4,5,8,-1,-1,-1}, {0,4,11,0,11,3,4,5,11,2,11,1,5,1,11}, {0,2,5,0,5,9,2,11,5,4,5,8,11,8,5}, {9,4,5,2,11,3,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {2,5,10,3,5,2,3,4,5,3,8,4,-1,-1,-1}, {5,10,2,5,2,4,4,2,0,-1,-1,-1,-1,-1,-1}, {3,10,2,3,5,10,3,8,5,4,5,8,0,1,9}, {5,10,2,5,2,4,1,9,2,9,4,2,-1,-1,-1}, {8,4,5,8,5,3,3,5,1,-1,-1,-1,-1,-1,-1}, {0,4,5,1,0,5,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {8,4,5,8,5,3,9,0,5,0,3,5,-1,-1,-1}, {9,4,5,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {4,11,7,4,9,11,9,10,11,-1,-1,-1,-1,-1,-1}, {0,8,3,4,9,7,9,11,7,9,10,11,-1,-1,-1}, {1,10,11,1,11,4,1,4,0,7,4,11,-1,-1,-1}, {3,1,4,3,4,8,1,10,4,7,4,11,10,11,4}, {4,11,7,9,11,4,9,2,11,9,1,2,-1,-1,-1}, {9,7,4,9,11,7,9,1,11,2,11,1,0,8,3}, {11,7,4,11,4,2,2,4,0,-1,-1,-1,-1,-1,-1}, {11,7,4,11,4,2,8,3,4,3,2,4,-1,-1,-1}, {2,9,10,2,7,9,2,3,7,7,4,9,-1,-1,-1}, {9,10,7,9,7,4,10,2,7,8,7,0,2,0,7}, {3,7,10,3,10,2,7,4,10,1,10,0,4,0,10}, {1,10,2,8,7,4,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {4,9,1,4,1,7,7,1,3,-1,-1,-1,-1,-1,-1}, {4,9,1,4,1,7,0,8,1,8,7,1,-1,-1,-1}, {4,0,3,7,4,3,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {4,8,7,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {9,10,8,10,11,8,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {3,0,9,3,9,11,11,9,10,-1,-1,-1,-1,-1,-1}, {0,1,10,0,10,8,8,10,11,-1,-1,-1,-1,-1,-1}, {3,1,10,11,3,10,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {1,2,11,1,11,9,9,11,8,-1,-1,-1,-1,-1,-1}, {3,0,9,3,9,11,1,2,9,2,11,9,-1,-1,-1}, {0,2,11,8,0,11,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {3,2,11,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {2,3,8,2,8,10,10,8,9,-1,-1,-1,-1,-1,-1}, {9,10,2,0,9,2,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {2,3,8,2,8,10,0,1,8,1,10,8,-1,-1,-1}, {1,10,2,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {1,3,8,9,1,8,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {0,9,1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {0,3,8,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1}, {-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1}}
-    edgeConnectList := []int32{-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,0,8,3,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,0,1,9,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,1,8,3,9,8,1,-1,-1,-1,-1,-1,-1,-1,-1,-1,1,2,10,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,0,8,3,1,2,10,-1,-1,-1,-1,-1,-1,-1,-1,-1,9,2,10,0,2,9,-1,-1,-1,-1,-1,-1,-1,-1,-1,2,8,3,2,10,8,10,9,8,-1,-1,-1,-1,-1,-1,3,11,2,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,0,11,2,8,11,0,-1,-1,-1,-1,-1,-1,-1,-1,-1,1,9,0,2,3,11,-1,-1,-1,-1,-1,-1,-1,-1,-1,1,11,2,1,9,11,9,8,11,-1,-1,-1,-1,-1,-1,3,10,1,11,10,3,-1,-1,-1,-1,-1,-1,-1,-1,-1,0,10,1,0,8,10,8,11,10,-1,-1,-1,-1,-1,-1,3,9,0,3,11,9,11,10,9,-1,-1,-1,-1,-1,-1,9,8,10,10,8,11,-1,-1,-1,-1,-1,-1,-1,-1,-1,4,7,8,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,4,3,0,7,3,4,-1,-1,-1,-1,-1,-1,-1,-1,-1,0,1,9,8,4,7,-1,-1,-1,-1,-1,-1,-1,-1,-1,4,1,9,4,7,1,7,3,1,-1,-1,-1,-1,-1,-1,1,2,10,8,4,7,-1,-1,-1,-1,-1,-1,-1,-1,-1,3,4,7,3,0,4,1,2,10,-1,-1,-1,-1,-1,-1,9,2,10,9,0,2,8,4,7,-1,-1,-1,-1,-1,-1,2,10,9,2,9,7,2,7,3,7,9,4,-1,-1,-1,8,4,7,3,11,2,-1,-1,-1,-1,-1,-1,-1,-1,-1,11,4,7,11,2,4,2,0,4,-1,-1,-1,-1,-1,-1,9,0,1,8,4,7,2,3,11,-1,-1,-1,-1,-1,-1,4,7,11,9,4,11,9,11,2,9,2,1,-1,-1,-1,3,10,1,3,11,10,7,8,4,-1,-1,-1,-1,-1,-1,1,11,10,1,4,11,1,0,4,7,11,4,-1,-1,-1,4,7,8,9,0,11,9,11,10,11,0,3,-1,-1,-1,4,7,11,4,11,9,9,11,10,-1,-1,-1,-1,-1,-1,9,5,4,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,9,5,4,0,8,3,-1,-1,-1,-1,-1,-1,-1,-1,-1,0,5,4,1,5,0,-1,-1,-1,-1,-1,-1,-1,-1,-1,8,5,4,8,3,5,3,1,5,-1,-1,-1,-1,-1,-1,1,2,10,9,5,4,-1,-1,-1,-1,-1,-1,-1,-1,-1,3,0,8,1,2,10,4,9,5,-1,-1,-1,-1,-1,-1,5,2,10,5,4,2,4,0,2,-1,-1,-1,-1,-1,-1,2,10,5,3,2,5,3,5,4,3,4,8,-1,-1,-1,9,5,4,2,3,11,-1,-1,-1,-1,-1,-1,-1,-1,-1,0,11,2,0,8,11,4,9,5,-1,-1,-1,-1,-1,-1,0,5,4,0,1,5,2,3,11,-1,-1,-1,-1,-1,-1,2,1,5,2,5,8,2,8,11,4,8,5,-1,-1,-1,10,3,11,10,1,3,9,5,4,-1,-1,-1,-1,-1,-1,4,9,5,0,8,1,8,10,1,8,11,10,-1,-1,-1,5,4,0,5,0,11,5,11,10,11,0,3,-1,-1,-1,5,4,8,5,8,10,10,8,11,-1,-1,-1,-1,-1,-1,9,7,8,5,7,9,-1,-1,-1,-1,-1,-1,-1,-1,-1,9,3,0,9,5,3,5,7,3,-1,-1,-1,-1,-1,-1,0,7,8,0,1,7,1,5,7,-1,-1,-1,-1,-1,-1,1,5,3,3,5,7,-1,-1,-1,-1,-1,-1,-1,-1,-1,9,7,8,9,5,7,10,1,2,-1,-1,-1,-1,-1,-1,10,1,2,9,5,0,5,3,0,5,7,3,-1,-1,-1,8,0,2,8,2,5,8,5,7,10,5,2,-1,-1,-1,2,10,5,2,5,3,3,5,7,-1,-1,-1,-1,-1,-1,7,9,5,7,8,9,3,11,2,-1,-1,-1,-1,-1,-1,9,5,7,9,7,2,9,2,0,2,7,11,-1,-1,-1,2,3,11,0,1,8,1,7,8,1,5,7,-1,-1,-1,11,2,1,11,1,7,7,1,5,-1,-1,-1,-1,-1,-1,9,5,8,8,5,7,10,1,3,10,3,11,-1,-1,-1,5,7,0,5,0,9,7,11,0,1,0,10,11,10,0,11,10,0,11,0,3,10,5,0,8,0,7,5,7,0,11,10,5,7,11,5,-1,-1,-1,-1,-1,-1,-1,-1,-1,10,6,5,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,0,8,3,5,10,6,-1,-1,-1,-1,-1,-1,-1,-1,-1,9,0,1,5,10,6,-1,-1,-1,-1,-1,-1,-1,-1,-1,1,8,3,1,9,8,5,10,6,-1,-1,-1,-1,-1,-1,1,6,5,2,6,1,-1,-1,-1,-1,-1,-1,-1,-1,-1,1,6,5,1,2,6,3,0,8,-1,-1,-1,-1,-1,-1,9,6,5,9,0,6,0,2,6,-1,-1,-1,-1,-1,-1,5,9,8,5,8,2,5,2,6,3,2,8,-1,-1,-1,2,3,11,10,6,5,-1,-1,-1,-1,-1,-1,-1,-1,-1,11,0,8,11,2,0,10,6,5,-1,-1,-1,-1,-1,-1,0,1,9,2,3,11,5,10,6,-1,-1,-1,-1,-1,-1,5,10,6,1,9,2,9,11,2,9,8,11,-1,-1,-1,6,3,11,6,5,3,5,1,3,-1,-1,-1,-1,-1,-1,0,8,11,0,11,5,0,5,1,5,11,6,-1,-1,-1,3,11,6,0,3,6,0,6,5,0,5,9,-1,-1,-1,6,5,9,6,9,11,11,9,8,-1,-1,-1,-1,-1,-1,5,10,6,4,7,8,-1,-1,-1,-1,-1,-1,-1,-1,-1,4,3,0,4,7,3,6,5,10,-1,-1,-1,-1,-1,-1,1,9,0,5,10,6,8,4,7,-1,-1,-1,-1,-1,-1,10,6,5,1,9,7,1,7,3,7,9,4,-1,-1,-1,6,1,2,6,5,1,4,7,8,-1,-1,-1,-1,-1,-1,1,2,5,5,2,6,3,0,4,3,4,7,-1,-1,-1,8,4,7,9,0,5,0,6,5,0,2,6,-1,-1,-1,7,3,9,7,9,4,3,2,9,5,9,6,2,6,9,3,11,2,7,8,4,10,6,5,-1,-1,-1,-1,-1,-1,5,10,6,4,7,2,4,2,0,2,7,11,-1,-1,-1,0,1,9,4,7,8,2,3,11,5,10,6,-1,-1,-1,9,2,1,9,11,2,9,4,11,7,11,4,5,10,6,8,4,7,3,11,5,3,5,1,5,11,6,-1,-1,-1,5,1,11,5,11,6,1,0,11,7,11,4,0,4,11,0,5,9,0,6,5,0,3,6,11,6,3,8,4,7,6,5,9,6,9,11,4,7,9,7,11,9,-1,-1,-1,10,4,9,6,4,10,-1,-1,-1,-1,-1,-1,-1,-1,-1,4,10,6,4,9,10,0,8,3,-1,-1,-1,-1,-1,-1,10,0,1,10,6,0,6,4,0,-1,-1,-1,-1,-1,-1,8,3,1,8,1,6,8,6,4,6,1,10,-1,-1,-1,1,4,9,1,2,4,2,6,4,-1,-1,-1,-1,-1,-1,3,0,8,1,2,9,2,4,9,2,6,4,-1,-1,-1,0,2,4,4,2,6,-1,-1,-1,-1,-1,-1,-1,-1,-1,8,3,2,8,2,4,4,2,6,-1,-1,-1,-1,-1,-1,10,4,9,10,6,4,11,2,3,-1,-1,-1,-1,-1,-1,0,8,2,2,8,11,4,9,10,4,10,6,-1,-1,-1,3,11,2,0,1,6,0,6,4,6,1,10,-1,-1,-1,6,4,1,6,1,10,4,8,1,2,1,11,8,11,1,9,6,4,9,3,6,9,1,3,11,6,3,-1,-1,-1,8,11,1,8,1,0,11,6,1,9,1,4,6,4,1,3,11,6,3,6,0,0,6,4,-1,-1,-1,-1,-1,-1,6,4,8,11,6,8,-1,-1,-1,-1,-1,-1,-1,-1,-1,7,10,6,7,8,10,8,9,10,-1,-1,-1,-1,-1,-1,0,7,3,0,10,7,0,9,10,6,7,10,-1,-1,-1,10,6,7,1,10,7,1,7,8,1,8,0,-1,-1,-1,10,6,7,10,7,1,1,7,3,-1,-1,-1,-1,-1,-1,1,2,6,1,6,8,1,8,9,8,6,7,-1,-1,-1,2,6,9,2,9,1,6,7,9,0,9,3,7,3,9,7,8,0,7,0,6,6,0,2,-1,-1,-1,-1,-1,-1,7,3,2,6,7,2,-1,-1,-1,-1,-1,-1,-1,-1,-1,2,3,11,10,6,8,10,8,9,8,6,7,-1,-1,-1,2,0,7,2,7,11,0,9,7,6,7,10,9,10,7,1,8,0,1,7,8,1,10,7,6,7,10,2,3,11,11,2,1,11,1,7,10,6,1,6,7,1,-1,-1,-1,8,9,6,8,6,7,9,1,6,11,6,3,1,3,6,0,9,1,11,6,7,-1,-1,-1,-1,-1,-1,-1,-1,-1,7,8,0,7,0,6,3,11,0,11,6,0,-1,-1,-1,7,11,6,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,7,6,11,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,3,0,8,11,7,6,-1,-1,-1,-1,-1,-1,-1,-1,-1,0,1,9,11,7,6,-1,-1,-1,-1,-1,-1,-1,-1,-1,8,1,9,8,3,1,11,7,6,-1,-1,-1,-1,-1,-1,10,1,2,6,11,7,-1,-1,-1,-1,-1,-1,-1,-1,-1,1,2,10,3,0,8,6,11,7,-1,-1,-1,-1,-1,-1,2,9,0,2,10,9,6,11,7,-1,-1,-1,-1,-1,-1,6,11,7,2,10,3,10,8,3,10,9,8,-1,-1,-1,7,2,3,6,2,7,-1,-1,-1,-1,-1,-1,-1,-1,-1,7,0,8,7,6,0,6,2,0,-1,-1,-1,-1,-1,-1,2,7,6,2,3,7,0,1,9,-1,-1,-1,-1,-1,-1,1,6,2,1,8,6,1,9,8,8,7,6,-1,-1,-1,10,7,6,10,1,7,1,3,7,-1,-1,-1,-1,-1,-1,10,7,6,1,7,10,1,8,7,1,0,8,-1,-1,-1,0,3,7,0,7,10,0,10,9,6,10,7,-1,-1,-1,7,6,10,7,10,8,8,10,9,-1,-1,-1,-1,-1,-1,6,8,4,11,8,6,-1,-1,-1,-1,-1,-1,-1,-1,-1,3,6,11,3,0,6,0,4,6,-1,-1,-1,-1,-1,-1,8,6,11,8,4,6,9,0,1,-1,-1,-1,-1,-1,-1,9,4,6,9,6,3,9,3,1,11,3,6,-1,-1,-1,6,8,4,6,11,8,2,10,1,-1,-1,-1,-1,-1,-1,1,2,10,3,0,11,0,6,11,0,4,6,-1,-1,-1,4,11,8,4,6,11,0,2,9,2,10,9,-1,-1,-1,10,9,3,10,3,2,9,4,3,11,3,6,4,6,3,8,2,3,8,4,2,4,6,2,-1,-1,-1,-1,-1,-1,0,4,2,4,6,2,-1,-1,-1,-1,-1,-1,-1,-1,-1,1,9,0,2,3,4,2,4,6,4,3,8,-1,-1,-1,1,9,4,1,4,2,2,4,6,-1,-1,-1,-1,-1,-1,8,1,3,8,6,1,8,4,6,6,10,1,-1,-1,-1,10,1,0,10,0,6,6,0,4,-1,-1,-1,-1,-1,-1,4,6,3,4,3,8,6,10,3,0,3,9,10,9,3,10,9,4,6,10,4,-1,-1,-1,-1,-1,-1,-1,-1,-1,4,9,5,7,6,11,-1,-1,-1,-1,-1,-1,-1,-1,-1,0,8,3,4,9,5,11,7,6,-1,-1,-1,-1,-1,-1,5,0,1,5,4,0,7,6,11,-1,-1,-1,-1,-1,-1,11,7,6,8,3,4,3,5,4,3,1,5,-1,-1,-1,9,5,4,10,1,2,7,6,11,-1,-1,-1,-1,-1,-1,6,11,7,1,2,10,0,8,3,4,9,5,-1,-1,-1,7,6,11,5,4,10,4,2,10,4,0,2,-1,-1,-1,3,4,8,3,5,4,3,2,5,10,5,2,11,7,6,7,2,3,7,6,2,5,4,9,-1,-1,-1,-1,-1,-1,9,5,4,0,8,6,0,6,2,6,8,7,-1,-1,-1,3,6,2,3,7,6,1,5,0,5,4,0,-1,-1,-1,6,2,8,6,8,7,2,1,8,4,8,5,1,5,8,9,5,4,10,1,6,1,7,6,1,3,7,-1,-1,-1,1,6,10,1,7,6,1,0,7,8,7,0,9,5,4,4,0,10,4,10,5,0,3,10,6,10,7,3,7,10,7,6,10,7,10,8,5,4,10,4,8,10,-1,-1,-1,6,9,5,6,11,9,11,8,9,-1,-1,-1,-1,-1,-1,3,6,11,0,6,3,0,5,6,0,9,5,-1,-1,-1,0,11,8,0,5,11,0,1,5,5,6,11,-1,-1,-1,6,11,3,6,3,5,5,3,1,-1,-1,-1,-1,-1,-1,1,2,10,9,5,11,9,11,8,11,5,6,-1,-1,-1,0,11,3,0,6,11,0,9,6,5,6,9,1,2,10,11,8,5,11,5,6,8,0,5,10,5,2,0,2,5,6,11,3,6,3,5,2,10,3,10,5,3,-1,-1,-1,5,8,9,5,2,8,5,6,2,3,8,2,-1,-1,-1,9,5,6,9,6,0,0,6,2,-1,-1,-1,-1,-1,-1,1,5,8,1,8,0,5,6,8,3,8,2,6,2,8,1,5,6,2,1,6,-1,-1,-1,-1,-1,-1,-1,-1,-1,1,3,6,1,6,10,3,8,6,5,6,9,8,9,6,10,1,0,10,0,6,9,5,0,5,6,0,-1,-1,-1,0,3,8,5,6,10,-1,-1,-1,-1,-1,-1,-1,-1,-1,10,5,6,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,11,5,10,7,5,11,-1,-1,-1,-1,-1,-1,-1,-1,-1,11,5,10,11,7,5,8,3,0,-1,-1,-1,-1,-1,-1,5,11,7,5,10,11,1,9,0,-1,-1,-1,-1,-1,-1,10,7,5,10,11,7,9,8,1,8,3,1,-1,-1,-1,11,1,2,11,7,1,7,5,1,-1,-1,-1,-1,-1,-1,0,8,3,1,2,7,1,7,5,7,2,11,-1,-1,-1,9,7,5,9,2,7,9,0,2,2,11,7,-1,-1,-1,7,5,2,7,2,11,5,9,2,3,2,8,9,8,2,2,5,10,2,3,5,3,7,5,-1,-1,-1,-1,-1,-1,8,2,0,8,5,2,8,7,5,10,2,5,-1,-1,-1,9,0,1,5,10,3,5,3,7,3,10,2,-1,-1,-1,9,8,2,9,2,1,8,7,2,10,2,5,7,5,2,1,3,5,3,7,5,-1,-1,-1,-1,-1,-1,-1,-1,-1,0,8,7,0,7,1,1,7,5,-1,-1,-1,-1,-1,-1,9,0,3,9,3,5,5,3,7,-1,-1,-1,-1,-1,-1,9,8,7,5,9,7,-1,-1,-1,-1,-1,-1,-1,-1,-1,5,8,4,5,10,8,10,11,8,-1,-1,-1,-1,-1,-1,5,0,4,5,11,0,5,10,11,11,3,0,-1,-1,-1,0,1,9,8,4,10,8,10,11,10,4,5,-1,-1,-1,10,11,4,10,4,5,11,3,4,9,4,1,3,1,4,2,5,1,2,8,5,2,11,8,4,5,8,-1,-1,-1,0,4,11,0,11,3,4,5,11,2,11,1,5,1,11,0,2,5,0,5,9,2,11,5,4,5,8,11,8,5,9,4,5,2,11,3,-1,-1,-1,-1,-1,-1,-1,-1,-1,2,5,10,3,5,2,3,4,5,3,8,4,-1,-1,-1,5,10,2,5,2,4,4,2,0,-1,-1,-1,-1,-1,-1,3,10,2,3,5,10,3,8,5,4,5,8,0,1,9,5,10,2,5,2,4,1,9,2,9,4,2,-1,-1,-1,8,4,5,8,5,3,3,5,1,-1,-1,-1,-1,-1,-1,0,4,5,1,0,5,-1,-1,-1,-1,-1,-1,-1,-1,-1,8,4,5,8,5,3,9,0,5,0,3,5,-1,-1,-1,9,4,5,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,4,11,7,4,9,11,9,10,11,-1,-1,-1,-1,-1,-1,0,8,3,4,9,7,9,11,7,9,10,11,-1,-1,-1,1,10,11,1,11,4,1,4,0,7,4,11,-1,-1,-1,3,1,4,3,4,8,1,10,4,7,4,11,10,11,4,4,11,7,9,11,4,9,2,11,9,1,2,-1,-1,-1,9,7,4,9,11,7,9,1,11,2,11,1,0,8,3,11,7,4,11,4,2,2,4,0,-1,-1,-1,-1,-1,-1,11,7,4,11,4,2,8,3,4,3,2,4,-1,-1,-1,2,9,10,2,7,9,2,3,7,7,4,9,-1,-1,-1,9,10,7,9,7,4,10,2,7,8,7,0,2,0,7,3,7,10,3,10,2,7,4,10,1,10,0,4,0,10,1,10,2,8,7,4,-1,-1,-1,-1,-1,-1,-1,-1,-1,4,9,1,4,1,7,7,1,3,-1,-1,-1,-1,-1,-1,4,9,1,4,1,7,0,8,1,8,7,1,-1,-1,-1,4,0,3,7,4,3,-1,-1,-1,-1,-1,-1,-1,-1,-1,4,8,7,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,9,10,8,10,11,8,-1,-1,-1,-1,-1,-1,-1,-1,-1,3,0,9,3,9,11,11,9,10,-1,-1,-1,-1,-1,-1,0,1,10,0,10,8,8,10,11,-1,-1,-1,-1,-1,-1,3,1,10,11,3,10,-1,-1,-1,-1,-1,-1,-1,-1,-1,1,2,11,1,11,9,9,11,8,-1,-1,-1,-1,-1,-1,3,0,9,3,9,11,1,2,9,2,11,9,-1,-1,-1,0,2,11,8,0,11,-1,-1,-1,-1,-1,-1,-1,-1,-1,3,2,11,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,2,3,8,2,8,10,10,8,9,-1,-1,-1,-1,-1,-1,9,10,2,0,9,2,-1,-1,-1,-1,-1,-1,-1,-1,-1,2,3,8,2,8,10,0,1,8,1,10,8,-1,-1,-1,1,10,2,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,1,3,8,9,1,8,-1,-1,-1,-1,-1,-1,-1,-1,-1,0,9,1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,0,3,8,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1,-1}
-
-    var edgeListABO uint32 = 0
-    gl.GenBuffers    (1, &edgeListABO);
-    gl.BindBuffer    (gl.ARRAY_BUFFER, edgeListABO);
-    gl.BufferData    (gl.ARRAY_BUFFER, 256*5*3*int(unsafe.Sizeof(int32(0))), gl.Ptr(edgeConnectList), gl.STATIC_READ);
-
-    gl.UseProgram(marchingCubesShaderID)
-    gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, 1, edgeListABO);
-    gl.UseProgram(0)
-
-
+// Invalid settings. The program exits with 2 instead of 1.
+type usageError struct {
+    error
 }
 
-// Here, the actual triangles are calculated and written into by the second marching cube shader invocation.
-// This buffer is later used for rendering!
-// Returns the positionArrayBuffer and vertexArrayBuffer.
-func createPositionBuffers(totalTriangleCount int) (uint32, uint32) {
-
-    var positionArrayBuffer  uint32
-    var positionVertexBuffer uint32
-
-    emptyVec := mgl32.Vec4{}
-    vec4Size := int(unsafe.Sizeof(emptyVec))
-
-    emptyVertex := Vertex{}
-    stride := int(unsafe.Sizeof(emptyVertex))
-
-    triangleSize := 3*stride
-
-    computeTriangles := make([]Triangle, totalTriangleCount)
-    for i,_ := range computeTriangles  {
-        computeTriangles[i] = Triangle{make([]Vertex, 3)}
+func main() {
+    if err := run(); err != nil {
+        fmt.Println(err)
+        if _, usage := err.(usageError); usage {
+            os.Exit(2)
+        }
+        os.Exit(1)
     }
-
-    gl.GenBuffers    (1, &positionArrayBuffer);
-    gl.BindBuffer    (gl.ARRAY_BUFFER, positionArrayBuffer);
-    // Data is ordered linear in memory :) (https://research.swtch.com/godata)
-    gl.BufferData    (gl.ARRAY_BUFFER, totalTriangleCount * triangleSize, gl.Ptr(&computeTriangles[0].Vertices[0].Pos[0]), gl.DYNAMIC_DRAW);
-
-    gl.GenVertexArrays(1, &positionVertexBuffer)
-    gl.BindVertexArray(positionVertexBuffer)
-
-    gl.EnableVertexAttribArray(0)
-    gl.VertexAttribPointer(0, 4, gl.FLOAT, false, int32(stride), gl.PtrOffset(0))
-    gl.EnableVertexAttribArray(1)
-    // If adding more attributes to a vertex, change the Offset and potentially stride here!
-    gl.VertexAttribPointer(1, 4, gl.FLOAT, true, int32(stride), gl.PtrOffset(vec4Size))
-
-    return positionArrayBuffer, positionVertexBuffer
 }
 
-// Each small cube writes into this buffer, how many triangles it wants to create.
-// Using this information, we can later fill the position buffer up, without having
-// empty positions.
-func createTriangleLayoutSizeBuffer(totalCubeCount int, marchingCubesShaderID uint32) uint32 {
-
-    triangleLayoutSizes := make([]int32, totalCubeCount)
-    var triangleLayoutSizesBuffer uint32
+// Everything of main, that needs cleaning up. os.Exit would skip the deferred calls
+// (i.e. terminating the headless context), so errors are returned to main instead.
+func run() error {
+    var err error = nil
+    var window *glfw.Window = nil
 
-    gl.GenBuffers    (1, &triangleLayoutSizesBuffer);
-    gl.BindBuffer    (gl.ARRAY_BUFFER, triangleLayoutSizesBuffer);
-    gl.BufferData    (gl.ARRAY_BUFFER, totalCubeCount*int(unsafe.Sizeof(int32(0))), gl.Ptr(&triangleLayoutSizes[0]), gl.DYNAMIC_COPY);
+    flag.Usage = printHelp
+    if err = parseConfig(); err != nil {
+        return usageError{err}
+    }
+    applyConfig()
+    if *g_pathFps <= 0 {
+        return usageError{fmt.Errorf("path-fps has to be positive, not %v", *g_pathFps)}
+    }
+    if err = loadBookmarks(); err != nil {
+        return usageError{err}
+    }
 
-    gl.UseProgram(marchingCubesShaderID)
-    gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, 3, triangleLayoutSizesBuffer);
-    gl.UseProgram(0)
+    if *g_checkTables {
+        if !runTableCheck() {
+            return fmt.Errorf("the tables differ from the generated ones")
+        }
+        return nil
+    }
 
-    return triangleLayoutSizesBuffer
+    if *g_headless || *g_regression || *g_meshTest || *g_sequenceDir != "" {
+        // Compiling the shaders alone takes minutes with a software renderer.
+        fmt.Println("compiling the shaders and creating the buffers")
+        // Same version as the window.
+        if err = InitHeadlessContext(4, 3); err != nil {
+            return err
+        }
+        defer TerminateHeadlessContext()
 
-}
+        // There is no window to render into.
+        CreateFbo(&g_outputFbo, &g_outputColorTex, &g_outputDepthTex, g_windowWidth, g_windowHeight, false)
+    } else {
+        if err = glfw.Init(); err != nil {
+            return err
+        }
+        // Terminate as soon, as this the function is finished.
+        defer glfw.Terminate()
+
+        window, err = initGraphicContext()
+        if err != nil {
+            // What happens with the error is decided in main
+            // and not in sub-functions
+            return err
+        }
+    }
 
+    // Without a shader directory, the shaders in the binary are used.
+    path := g_config.ShaderPath
+    if path == "" {
+        ShaderFiles = EmbeddedShaders
+    }
+    g_renderProgram, err = WatchProgram(path+"vertexShader.vert", path+"fragmentShader.frag", ShaderDefines{"SHADOW_CASCADES": g_shadowCascades, "MAX_POINT_LIGHTS": g_maxPointLights})
+    if err != nil {
+        return err
+    }
+    defer g_renderProgram.Close()
 
-// A Buffer where the actual cases (for all corners of the cube) are written into.
-func createCasesBuffer(totalCubeCount int, marchingCubesShaderID uint32) {
-    cases := make([]int32, totalCubeCount)
+    g_shadowProgram, err = WatchProgram(path+"shadow.vert", path+"shadow.frag", nil)
+    if err != nil {
+        return err
+    }
+    defer g_shadowProgram.Close()
 
-    var casesABO uint32 = 0
-    gl.GenBuffers    (1, &casesABO);
-    gl.BindBuffer    (gl.ARRAY_BUFFER, casesABO);
-    gl.BufferData    (gl.ARRAY_BUFFER, totalCubeCount*int(unsafe.Sizeof(int32(0))), gl.Ptr(&cases[0]), gl.STATIC_READ);
+    g_tonemapProgram, err = WatchProgram(path+"fullscreen.vert", path+"tonemap.frag", nil)
+    if err != nil {
+        return err
+    }
+    defer g_tonemapProgram.Close()
 
-    gl.UseProgram(marchingCubesShaderID)
-    gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, 4, casesABO);
-    gl.UseProgram(0)
-}
+    CreateFboWithFormat(&g_hdrFbo, &g_hdrColorTex, &g_hdrDepthTex, g_windowWidth, g_windowHeight, gl.RGBA16F, false)
 
-func main() {
-    var err error = nil
-    if err = glfw.Init(); err != nil {
-        panic(err)
+    g_normalsProgram, err = WatchProgram(path+"normals.vert", path+"normals.frag", nil)
+    if err != nil {
+        return err
     }
-    // Terminate as soon, as this the function is finished.
-    defer glfw.Terminate()
-
-    window, err := initGraphicContext()
+    defer g_normalsProgram.Close()
+    g_ssaoProgram, err = WatchProgram(path+"fullscreen.vert", path+"ssao.frag", ShaderDefines{"MAX_SSAO_SAMPLES": g_maxSsaoSamples})
+    if err != nil {
+        return err
+    }
+    defer g_ssaoProgram.Close()
+    g_ssaoBlurProgram, err = WatchProgram(path+"fullscreen.vert", path+"ssaoBlur.frag", nil)
+    if err != nil {
+        return err
+    }
+    defer g_ssaoBlurProgram.Close()
+    g_ssaoDebugProgram, err = WatchProgram(path+"fullscreen.vert", path+"ssaoDebug.frag", nil)
     if err != nil {
-        // Decision to panic or do something different is taken in the main
-        // method and not in sub-functions
-        panic(err)
+        return err
     }
+    defer g_ssaoDebugProgram.Close()
+
+    g_ssao = NewSSAO(g_windowWidth, g_windowHeight, 16, 1.5)
+    gl.GenVertexArrays(1, &g_screenVertexArray)
 
-    path := "../Go/src/GPUTerrain/"
-    g_ShaderID, err = NewProgram(path+"vertexShader.vert", path+"fragmentShader.frag")
+    g_shadowMaps = NewCascadedShadowMaps(g_shadowCascades, g_shadowMapSize, g_shadowDistance)
+
+    // Put grass.png, rock.png or sand.png in there to replace the generated textures.
+    // Next to the shaders or in the working directory.
+    terrainTextures, err := TerrainTextures(path+"textures", g_terrainTextureSize)
     if err != nil {
-        panic(err)
+        return err
+    }
+    layers := make([][]uint8, len(terrainTextures))
+    for i,texture := range terrainTextures {
+        layers[i] = texture.Pix
     }
+    CreateTextureArray(&g_terrainTextures, g_terrainTextureSize, g_terrainTextureSize, layers)
 
 
 
-    g_light = CreateObject(CreateUnitSphere(10), mgl32.Vec3{3,15,0}, mgl32.Vec3{0.2,0.2,0.2}, mgl32.Vec3{1,1,0}, true)
+    g_light = CreateObject(CreateUnitSphere(10), mgl32.Vec3{float32(g_config.LightPos[0]), float32(g_config.LightPos[1]), float32(g_config.LightPos[2])}, mgl32.Vec3{0.2,0.2,0.2}, mgl32.Vec3{1,1,0}, true)
+
+    g_pointLights = []PointLight {
+        PointLight{CreateObject(CreateUnitSphere(10), mgl32.Vec3{40,14,40},  mgl32.Vec3{0.3,0.3,0.3}, mgl32.Vec3{1.0,0.6,0.3}, true), 300, 40},
+        PointLight{CreateObject(CreateUnitSphere(10), mgl32.Vec3{120,14,110}, mgl32.Vec3{0.3,0.3,0.3}, mgl32.Vec3{0.4,0.6,1.0}, true), 300, 40},
+    }
 
 
-    trianglesPerCube        := float32(2.0)
-    marchingCubeCountWidth  := 15
-    marchingCubeCountHeight := 1
-    marchingCubeCountDepth  := 15
+    trianglesPerCube        := float32(g_config.TrianglesPerCube)
+    marchingCubeCountWidth  := g_config.Units[0]
+    marchingCubeCountHeight := g_config.Units[1]
+    marchingCubeCountDepth  := g_config.Units[2]
     marchingCubeCount := marchingCubeCountWidth * marchingCubeCountHeight * marchingCubeCountDepth
     marchingCubeUnits := make([]MarchingCubeUnit, marchingCubeCount, marchingCubeCount)
 
-    addedLocalWorkgroupCount := 0
-
     for x := 0; x < marchingCubeCountWidth; x+=1 {
         for y := 0; y < marchingCubeCountHeight; y+=1 {
             for z := 0; z < marchingCubeCountDepth; z+=1 {
@@ -535,36 +1204,52 @@ func main() {
                     PositionOffset:         mgl32.Vec3{float32(x*g_cubeWidth),float32(y*g_cubeHeight),float32(z*g_cubeDepth)},
                     LocalWorkGroupCount:    g_cubeWidth * g_cubeHeight * g_cubeDepth,
                     RenderTriangleCount:    0,
-                    BoxOutline:             CreateObject(CreateUnitCube(1), mgl32.Vec3{float32(x*g_cubeWidth+5),float32(y*g_cubeHeight+5),float32(z*g_cubeDepth+5)}, mgl32.Vec3{10,10,10}, mgl32.Vec3{1,0,0}, false),
+                    BoxOutline:             CreateObject(CreateUnitCube(1), mgl32.Vec3{float32(x*g_cubeWidth)+float32(g_cubeWidth)/2.0,float32(y*g_cubeHeight)+float32(g_cubeHeight)/2.0,float32(z*g_cubeDepth)+float32(g_cubeDepth)/2.0}, mgl32.Vec3{float32(g_cubeWidth),float32(g_cubeHeight),float32(g_cubeDepth)}, mgl32.Vec3{1,0,0}, false),
                     ShowOutline:            true,
+                    Dirty:                  true,
                 }
-                addedLocalWorkgroupCount += marchingCubeUnits[i].LocalWorkGroupCount
             }
         }
     }
 
-    marchingCubesProgram, err := NewComputeProgram(path+"marchingCubes.comp")
-    if err != nil {
-        panic(err)
+    // The inner surface is the actual terrain. The outer one is a transparent shell around it.
+    isoSurfaces := []IsoSurface {
+        IsoSurface{IsoLevel: 0.0, Color: mgl32.Vec3{1,0,0},       Alpha: 1.0,  Textured: true, Roughness: 0.8},
+        IsoSurface{IsoLevel: 1.0, Color: mgl32.Vec3{0.2,0.4,1.0}, Alpha: 0.35, Roughness: 0.1},
+    }
+    if len(isoSurfaces) > g_maxIsoSurfaces {
+        return fmt.Errorf("at most %v iso-surfaces are supported", g_maxIsoSurfaces)
     }
 
-    positionArrayBuffer, positionVertexBuffer := createPositionBuffers(int(float32(addedLocalWorkgroupCount) * trianglesPerCube))
-    triangleLayoutSizesBuffer := createTriangleLayoutSizeBuffer(addedLocalWorkgroupCount, marchingCubesProgram)
-    createMarchingCubeConstBuffers(marchingCubesProgram)
-    createCasesBuffer(addedLocalWorkgroupCount, marchingCubesProgram)
-
-    g_marchingCubes = MarchingCubes {
-        ShaderID:               marchingCubesProgram,
-        // Absolute worst-case!!! Correct that later.
-        TriangleCount:          int(float32(addedLocalWorkgroupCount) * trianglesPerCube),
+    g_parameters = declareTerrainParameters()
 
-        PositionArrayBuffer:    positionArrayBuffer,
-        PositionVertexBuffer:   positionVertexBuffer,
-        TriangleLayoutSizesBuffer: triangleLayoutSizesBuffer,
+    g_marchingCubesProgram, err = WatchComputeProgram(path+"marchingCubes.comp", marchingCubesDefines())
+    if err != nil {
+        return err
+    }
+    defer g_marchingCubesProgram.Close()
 
+    grid := Grid {
+        ChunkSize: [3]int{g_cubeWidth, g_cubeHeight, g_cubeDepth},
+        CubeSize:  1.0,
+    }
+    for _,unit := range marchingCubeUnits {
+        grid.UnitOffsets = append(grid.UnitOffsets, unit.PositionOffset)
+    }
+    for _,surface := range isoSurfaces {
+        grid.IsoLevels = append(grid.IsoLevels, surface.IsoLevel)
+    }
 
+    g_marchingCubes = MarchingCubes {
+        MarchingCubesBuffers:   NewMarchingCubesBuffers(grid, [3]int{g_workGroupSizeX, g_workGroupSizeY, g_workGroupSizeZ}, trianglesPerCube),
         UnitCount:              marchingCubeCount,
         MarchingCubeUnits:      marchingCubeUnits,
+        IsoSurfaces:            isoSurfaces,
+    }
+    defer g_marchingCubes.Delete()
+
+    if err = g_marchingCubes.CheckDispatchLimits(); err != nil {
+        return err
     }
 
     //g_marchingCubesBoxOutline = CreateObject(CreateUnitCube(1), mgl32.Vec3{5,5,5}, mgl32.Vec3{10,10,10}, mgl32.Vec3{1,0,0}, false)
@@ -576,9 +1261,27 @@ func main() {
 
     gl.PointSize(3.0);
 
+    if *g_meshTest {
+        if !runMeshTest() {
+            return fmt.Errorf("the mesh test failed")
+        }
+        return nil
+    }
+    if *g_regression {
+        if !runRegression() {
+            return fmt.Errorf("the regression test failed")
+        }
+        return nil
+    }
+    if *g_sequenceDir != "" {
+        return renderSequence()
+    }
+    if *g_headless {
+        return renderHeadless()
+    }
 
     mainLoop(window)
-
+    return nil
 }
 
 
@@ -0,0 +1,232 @@
+package main
+
+// A minimal glTF 2.0 binary (.glb) writer. Every iso-surface becomes its own mesh with
+// non-indexed triangles, like they come out of marching cubes. Besides positions and normals,
+// every vertex has the colour (COLOR_0), the material ID (_MATERIAL) and the baked ambient occlusion (_OCCLUSION).
+// See: https://registry.khronos.org/glTF/specs/2.0/glTF-2.0.html#glb-file-format-specification
+
+import (
+    . "GPUTerrain/Mesher"
+    "github.com/go-gl/mathgl/mgl32"
+    "bytes"
+    "encoding/binary"
+    "encoding/json"
+    "fmt"
+    "io"
+    "math"
+    "os"
+)
+
+const (
+    glbMagic        = 0x46546C67
+    glbVersion      = 2
+    glbChunkJSON    = 0x4E4F534A
+    glbChunkBIN     = 0x004E4942
+    gltfFloat       = 5126
+    gltfArrayBuffer = 34962
+    gltfTriangles   = 4
+)
+
+// One iso-surface of the output. Same vertex attributes as Mesh.
+type Surface struct {
+    Name        string
+    Triangles   []MeshTriangle
+    Normals     []MeshTriangle
+    MaterialIDs [][3]float32
+    Colors      [][3]mgl32.Vec4
+    Occlusion   [][3]float32
+}
+
+type gltfAccessor struct {
+    BufferView      int         `json:"bufferView"`
+    ComponentType   int         `json:"componentType"`
+    Count           int         `json:"count"`
+    Type            string      `json:"type"`
+    Min             []float32   `json:"min,omitempty"`
+    Max             []float32   `json:"max,omitempty"`
+}
+
+type gltfBufferView struct {
+    Buffer      int     `json:"buffer"`
+    ByteOffset  int     `json:"byteOffset"`
+    ByteLength  int     `json:"byteLength"`
+    Target      int     `json:"target"`
+}
+
+type gltfPrimitive struct {
+    Attributes  map[string]int  `json:"attributes"`
+    Mode        int             `json:"mode"`
+}
+
+type gltfMesh struct {
+    Name        string          `json:"name"`
+    Primitives  []gltfPrimitive `json:"primitives"`
+}
+
+type gltfNode struct {
+    Name    string  `json:"name"`
+    Mesh    int     `json:"mesh"`
+}
+
+type gltfDocument struct {
+    Asset       map[string]string       `json:"asset"`
+    Scene       *int                    `json:"scene,omitempty"`
+    Scenes      []map[string][]int      `json:"scenes,omitempty"`
+    Nodes       []gltfNode              `json:"nodes,omitempty"`
+    Meshes      []gltfMesh              `json:"meshes,omitempty"`
+    Accessors   []gltfAccessor          `json:"accessors,omitempty"`
+    BufferViews []gltfBufferView        `json:"bufferViews,omitempty"`
+    Buffers     []map[string]int        `json:"buffers,omitempty"`
+}
+
+// glTF requires normalized normals. Degenerated gradients get the normal of the triangle instead.
+func unitNormal(normal mgl32.Vec3, triangle MeshTriangle) mgl32.Vec3 {
+    if l := normal.Len(); l > 1e-6 && !math.IsNaN(float64(l)) {
+        return normal.Mul(1.0/l)
+    }
+    face := triangle[1].Sub(triangle[0]).Cross(triangle[2].Sub(triangle[0]))
+    if l := face.Len(); l > 1e-12 {
+        return face.Mul(1.0/l)
+    }
+    return mgl32.Vec3{0,1,0}
+}
+
+// COLOR_0 multiplies the base colour in every viewer. The colour of the shader is blended over
+// the surface by its alpha instead, so it is blended over white here. Alpha stays as it is.
+func vertexColor(color mgl32.Vec4) mgl32.Vec4 {
+    a := mgl32.Clamp(color[3], 0, 1)
+    var blended mgl32.Vec4
+    for k := 0; k < 3; k++ {
+        blended[k] = mgl32.Clamp(1 - a + a*color[k], 0, 1)
+    }
+    blended[3] = a
+    return blended
+}
+
+// Writes the values (a slice of float32, mgl32.Vec3 or mgl32.Vec4) as one buffer view and accessor.
+// Returns the accessor index.
+func (d *gltfDocument) addAttribute(data *bytes.Buffer, values interface{}, count int, accessorType string) int {
+    offset := data.Len()
+    binary.Write(data, binary.LittleEndian, values)
+    d.BufferViews = append(d.BufferViews, gltfBufferView{0, offset, data.Len() - offset, gltfArrayBuffer})
+    d.Accessors = append(d.Accessors, gltfAccessor {
+        BufferView:    len(d.BufferViews)-1,
+        ComponentType: gltfFloat,
+        Count:         count,
+        Type:          accessorType,
+    })
+    return len(d.Accessors)-1
+}
+
+// Writes the vertices as one buffer view and accessor. Returns the accessor index.
+func (d *gltfDocument) addVec3s(data *bytes.Buffer, vertices []mgl32.Vec3, withBounds bool) int {
+    index := d.addAttribute(data, vertices, len(vertices), "VEC3")
+    accessor := &d.Accessors[index]
+    if withBounds {
+        accessor.Min = []float32{vertices[0][0], vertices[0][1], vertices[0][2]}
+        accessor.Max = []float32{vertices[0][0], vertices[0][1], vertices[0][2]}
+        for _,v := range vertices {
+            for k := 0; k < 3; k++ {
+                accessor.Min[k] = float32(math.Min(float64(accessor.Min[k]), float64(v[k])))
+                accessor.Max[k] = float32(math.Max(float64(accessor.Max[k]), float64(v[k])))
+            }
+        }
+    }
+    return index
+}
+
+// Surfaces without triangles are left out, glTF does not allow empty accessors.
+// Without any triangles, the file has no scene, because a scene needs at least one node.
+func WriteGLB(w io.Writer, surfaces []Surface) error {
+    document := gltfDocument {
+        Asset:  map[string]string{"version": "2.0", "generator": "mcubes"},
+    }
+    var data bytes.Buffer
+
+    for _,surface := range surfaces {
+        if len(surface.Triangles) == 0 {
+            continue
+        }
+        count := 3*len(surface.Triangles)
+        positions := make([]mgl32.Vec3, 0, count)
+        normals := make([]mgl32.Vec3, 0, count)
+        colors := make([]mgl32.Vec4, 0, count)
+        materialIDs := make([]float32, 0, count)
+        occlusion := make([]float32, 0, count)
+        for t,triangle := range surface.Triangles {
+            for v := 0; v < 3; v++ {
+                positions = append(positions, triangle[v])
+                normals = append(normals, unitNormal(surface.Normals[t][v], triangle))
+                colors = append(colors, vertexColor(surface.Colors[t][v]))
+                materialIDs = append(materialIDs, surface.MaterialIDs[t][v])
+                occlusion = append(occlusion, surface.Occlusion[t][v])
+            }
+        }
+
+        primitive := gltfPrimitive {
+            Attributes: map[string]int {
+                "POSITION":   document.addVec3s(&data, positions, true),
+                "NORMAL":     document.addVec3s(&data, normals, false),
+                "COLOR_0":    document.addAttribute(&data, colors, count, "VEC4"),
+                // Application specific attributes start with an underscore.
+                "_MATERIAL":  document.addAttribute(&data, materialIDs, count, "SCALAR"),
+                "_OCCLUSION": document.addAttribute(&data, occlusion, count, "SCALAR"),
+            },
+            Mode: gltfTriangles,
+        }
+        document.Meshes = append(document.Meshes, gltfMesh{surface.Name, []gltfPrimitive{primitive}})
+        document.Nodes = append(document.Nodes, gltfNode{surface.Name, len(document.Meshes)-1})
+    }
+    if len(document.Nodes) > 0 {
+        var nodes []int
+        for i,_ := range document.Nodes {
+            nodes = append(nodes, i)
+        }
+        scene := 0
+        document.Scene = &scene
+        document.Scenes = []map[string][]int{{"nodes": nodes}}
+        document.Buffers = []map[string]int{{"byteLength": data.Len()}}
+    }
+
+    jsonChunk, err := json.Marshal(document)
+    if err != nil {
+        return err
+    }
+    // Both chunks have to be aligned to 4 bytes. JSON with spaces, the binary data with zeros.
+    for len(jsonChunk) % 4 != 0 {
+        jsonChunk = append(jsonChunk, ' ')
+    }
+    binChunk := data.Bytes()
+    for len(binChunk) % 4 != 0 {
+        binChunk = append(binChunk, 0)
+    }
+
+    length := 12 + 8 + len(jsonChunk)
+    if len(binChunk) > 0 {
+        length += 8 + len(binChunk)
+    }
+
+    var out bytes.Buffer
+    binary.Write(&out, binary.LittleEndian, []uint32{glbMagic, glbVersion, uint32(length)})
+    binary.Write(&out, binary.LittleEndian, []uint32{uint32(len(jsonChunk)), glbChunkJSON})
+    out.Write(jsonChunk)
+    if len(binChunk) > 0 {
+        binary.Write(&out, binary.LittleEndian, []uint32{uint32(len(binChunk)), glbChunkBIN})
+        out.Write(binChunk)
+    }
+
+    _, err = w.Write(out.Bytes())
+    return err
+}
+
+func SaveGLB(fileName string, surfaces []Surface) error {
+    file, err := os.Create(fileName)
+    if err != nil {
+        return err
+    }
+    if err := WriteGLB(file, surfaces); err != nil {
+        file.Close()
+        return fmt.Errorf("%v: %v", fileName, err)
+    }
+    return file.Close()
+}
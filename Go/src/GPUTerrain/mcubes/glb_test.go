@@ -0,0 +1,313 @@
+package main
+
+import (
+    . "GPUTerrain/Mesher"
+    "github.com/go-gl/mathgl/mgl32"
+    "bytes"
+    "encoding/binary"
+    "encoding/json"
+    "io/ioutil"
+    "math"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+// Splits a .glb into its JSON document and binary chunk and checks the header and the alignment on the way.
+func readGLB(t *testing.T, glb []byte) (gltfDocument, []byte) {
+    var header [3]uint32
+    if err := binary.Read(bytes.NewReader(glb), binary.LittleEndian, &header); err != nil {
+        t.Fatal(err)
+    }
+    if header[0] != glbMagic || header[1] != glbVersion || int(header[2]) != len(glb) {
+        t.Fatalf("header %x, %v bytes", header, len(glb))
+    }
+
+    var document gltfDocument
+    var bin []byte
+    for offset, chunk := 12, 0; offset < len(glb); chunk++ {
+        length := int(binary.LittleEndian.Uint32(glb[offset:]))
+        chunkType := binary.LittleEndian.Uint32(glb[offset+4:])
+        if length % 4 != 0 || offset+8+length > len(glb) {
+            t.Fatalf("chunk %v has %v bytes at %v of %v", chunk, length, offset, len(glb))
+        }
+        content := glb[offset+8 : offset+8+length]
+        switch {
+            case chunk == 0 && chunkType == glbChunkJSON:
+                if err := json.Unmarshal(content, &document); err != nil {
+                    t.Fatal(err)
+                }
+            case chunk == 1 && chunkType == glbChunkBIN:
+                bin = content
+            default:
+                t.Fatalf("chunk %v has the type %x", chunk, chunkType)
+        }
+        offset += 8 + length
+    }
+    return document, bin
+}
+
+func testSurface(name string, triangles ...MeshTriangle) Surface {
+    s := Surface{Name: name, Triangles: triangles}
+    for _,_ = range triangles {
+        s.Normals = append(s.Normals, MeshTriangle{{0, 2, 0}, {0, 0, 0}, {0, 1, 0}})
+        s.MaterialIDs = append(s.MaterialIDs, [3]float32{1, 2, 3})
+        s.Colors = append(s.Colors, [3]mgl32.Vec4{{1, 0, 0, 1}, {1, 0, 0, 0.5}, {1, 0, 0, 0}})
+        s.Occlusion = append(s.Occlusion, [3]float32{0, 0.5, 1})
+    }
+    return s
+}
+
+func TestWriteGLB(t *testing.T) {
+    surfaces := []Surface {
+        testSurface("iso 0", MeshTriangle{{0, 0, 0}, {1, 0, 0}, {0, 0, 1}}, MeshTriangle{{-1, 2, 0}, {1, 0, 3}, {0, 0, 1}}),
+        testSurface("iso 1"),
+        // An odd name, so that the JSON chunk needs padding.
+        testSurface("iso 2.5", MeshTriangle{{5, 5, 5}, {6, 5, 5}, {5, 5, 6}}),
+    }
+    var out bytes.Buffer
+    if err := WriteGLB(&out, surfaces); err != nil {
+        t.Fatal(err)
+    }
+    document, bin := readGLB(t, out.Bytes())
+
+    // The empty surface is left out.
+    if len(document.Meshes) != 2 || document.Meshes[1].Name != "iso 2.5" || len(document.Nodes) != 2 {
+        t.Fatalf("meshes %v, nodes %v", document.Meshes, document.Nodes)
+    }
+    if document.Scene == nil || *document.Scene != 0 || len(document.Scenes) != 1 || len(document.Scenes[0]["nodes"]) != 2 {
+        t.Errorf("scene %v, scenes %v", document.Scene, document.Scenes)
+    }
+    if len(document.Buffers) != 1 || document.Buffers[0]["byteLength"] > len(bin) {
+        t.Errorf("buffers %v for %v bytes", document.Buffers, len(bin))
+    }
+
+    sizes := map[string]int{"SCALAR": 4, "VEC3": 12, "VEC4": 16}
+    for m,triangles := range []int{2, 1} {
+        for name,a := range document.Meshes[m].Primitives[0].Attributes {
+            accessor := document.Accessors[a]
+            view := document.BufferViews[accessor.BufferView]
+            if accessor.Count != 3*triangles || view.ByteLength != accessor.Count*sizes[accessor.Type] {
+                t.Errorf("mesh %v, %v: %v vertices in %v bytes", m, name, accessor.Count, view.ByteLength)
+            }
+        }
+    }
+
+    // POSITION round-trips with the bounds of its vertices.
+    position := document.Accessors[document.Meshes[0].Primitives[0].Attributes["POSITION"]]
+    view := document.BufferViews[position.BufferView]
+    vertices := make([]mgl32.Vec3, position.Count)
+    if err := binary.Read(bytes.NewReader(bin[view.ByteOffset:view.ByteOffset+view.ByteLength]), binary.LittleEndian, vertices); err != nil {
+        t.Fatal(err)
+    }
+    for i,v := range vertices {
+        if v != surfaces[0].Triangles[i/3][i%3] {
+            t.Errorf("vertex %v: %v instead of %v", i, v, surfaces[0].Triangles[i/3][i%3])
+        }
+    }
+    if !(mgl32.Vec3{-1, 0, 0}).ApproxEqual(mgl32.Vec3{position.Min[0], position.Min[1], position.Min[2]}) ||
+        !(mgl32.Vec3{1, 2, 3}).ApproxEqual(mgl32.Vec3{position.Max[0], position.Max[1], position.Max[2]}) {
+        t.Errorf("POSITION from %v to %v", position.Min, position.Max)
+    }
+}
+
+// glTF needs at least one node in a scene, so a file without triangles has no scene at all.
+func TestWriteGLBEmpty(t *testing.T) {
+    var out bytes.Buffer
+    if err := WriteGLB(&out, []Surface{testSurface("iso 0")}); err != nil {
+        t.Fatal(err)
+    }
+    document, bin := readGLB(t, out.Bytes())
+    if document.Scene != nil || document.Scenes != nil || document.Nodes != nil || document.Buffers != nil || bin != nil {
+        t.Errorf("an empty file has: %+v and %v bytes", document, len(bin))
+    }
+}
+
+func TestUnitNormal(t *testing.T) {
+    triangle := MeshTriangle{{0, 0, 0}, {0, 0, 1}, {1, 0, 0}}
+    tests := []struct {
+        normal, expected    mgl32.Vec3
+    }{
+        {mgl32.Vec3{0, 3, 4}, mgl32.Vec3{0, 0.6, 0.8}},
+        // Degenerated gradients get the normal of the triangle.
+        {mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 1, 0}},
+        {mgl32.Vec3{float32(math.NaN()), 0, 0}, mgl32.Vec3{0, 1, 0}},
+    }
+    for _,test := range tests {
+        if n := unitNormal(test.normal, triangle); !n.ApproxEqual(test.expected) {
+            t.Errorf("%v: %v instead of %v", test.normal, n, test.expected)
+        }
+    }
+    // Without a triangle as well.
+    if n := unitNormal(mgl32.Vec3{}, MeshTriangle{}); n != (mgl32.Vec3{0, 1, 0}) {
+        t.Errorf("degenerated triangle: %v", n)
+    }
+}
+
+func TestVertexColor(t *testing.T) {
+    tests := []struct {
+        color, expected     mgl32.Vec4
+    }{
+        {mgl32.Vec4{1, 0, 0, 1}, mgl32.Vec4{1, 0, 0, 1}},
+        {mgl32.Vec4{1, 0, 0, 0}, mgl32.Vec4{1, 1, 1, 0}},
+        {mgl32.Vec4{0, 0, 0, 0.5}, mgl32.Vec4{0.5, 0.5, 0.5, 0.5}},
+        {mgl32.Vec4{2, -1, 0, 1.5}, mgl32.Vec4{1, 0, 0, 1}},
+    }
+    for _,test := range tests {
+        if c := vertexColor(test.color); !c.ApproxEqual(test.expected) {
+            t.Errorf("%v: %v instead of %v", test.color, c, test.expected)
+        }
+    }
+}
+
+func TestParseBounds(t *testing.T) {
+    tests := []struct {
+        s       string
+        valid   bool
+    }{
+        {"0,0,0,150,10,150", true},
+        {" -1, -2.5, 0, 1, 1e3, 1 ", true},
+        {"0,0,0,1,1", false},
+        {"0,0,0,1,1,1,1", false},
+        {"", false},
+        {"0,0,0,1,x,1", false},
+        {"0,0,0,NaN,1,1", false},
+        {"0,0,-Inf,1,1,1", false},
+        {"0,0,0,1,1,+Inf", false},
+        {"0,0,0,1,0,1", false},
+        {"0,0,0,1,-1,1", false},
+    }
+    for _,test := range tests {
+        if _, err := parseBounds(test.s); (err == nil) != test.valid {
+            t.Errorf("%q: %v", test.s, err)
+        }
+    }
+
+    b, _ := parseBounds(" -1, -2.5, 0, 1, 1e3, 1 ")
+    if b != (Bounds{mgl32.Vec3{-1, -2.5, 0}, mgl32.Vec3{1, 1000, 1}}) {
+        t.Errorf("parsed %v", b)
+    }
+}
+
+func TestParseFloats(t *testing.T) {
+    values, err := parseFloats("0, -1.5,2e1")
+    if err != nil || len(values) != 3 || values[0] != 0 || values[1] != -1.5 || values[2] != 20 {
+        t.Errorf("%v, %v", values, err)
+    }
+    for _,s := range []string{"", "0,", "NaN", "0,inf", "-Infinity", "1e40"} {
+        if values, err := parseFloats(s); err == nil {
+            t.Errorf("%q gives %v", s, values)
+        }
+    }
+}
+
+func TestBoundsGrid(t *testing.T) {
+    tests := []struct {
+        bounds      Bounds
+        resolution  float32
+        cubes       [3]int
+        units       int
+    }{
+        {Bounds{mgl32.Vec3{0, 0, 0}, mgl32.Vec3{32, 32, 32}}, 1, [3]int{32, 32, 32}, 1},
+        {Bounds{mgl32.Vec3{0, 0, 0}, mgl32.Vec3{33, 1, 64}}, 1, [3]int{33, 1, 64}, 2*1*2},
+        {Bounds{mgl32.Vec3{-10, 0, 0}, mgl32.Vec3{10, 0.5, 0.5}}, 2, [3]int{40, 1, 1}, 2},
+        {Bounds{mgl32.Vec3{0, 0, 0}, mgl32.Vec3{150, 10, 150}}, 0.5, [3]int{75, 5, 75}, 3*1*3},
+    }
+    for _,test := range tests {
+        grid, cubes := boundsGrid(test.bounds, test.resolution, []float32{0, 1})
+        if cubes != test.cubes || len(grid.UnitOffsets) != test.units {
+            t.Errorf("%v at %v: %v cubes in %v units", test.bounds, test.resolution, cubes, len(grid.UnitOffsets))
+        }
+        // Unit offsets are in cubes, starting at the minimum of the bounds.
+        if origin := test.bounds.Min.Mul(test.resolution); grid.UnitOffsets[0] != origin || grid.CubeSize != 1/test.resolution {
+            t.Errorf("%v at %v: first unit at %v, cube size %v", test.bounds, test.resolution, grid.UnitOffsets[0], grid.CubeSize)
+        }
+    }
+}
+
+// A plane at y = 20.5 through two units (40 cubes wide), cropped to less than both.
+func TestCropSurfaces(t *testing.T) {
+    bounds := Bounds{mgl32.Vec3{0, 0, 0}, mgl32.Vec3{40, 30, 5}}
+    grid, cubes := boundsGrid(bounds, 1, []float32{0})
+    plane := func(pos mgl32.Vec3) float32 {
+        return pos[1] - 20.5
+    }
+    mesh, err := Extract(grid, plane, ShippedTables)
+    if err != nil {
+        t.Fatal(err)
+    }
+    // Only the positions are needed, the other attributes are cropped the same way.
+    mesh.Normals = make([]MeshTriangle, len(mesh.Triangles))
+    mesh.MaterialIDs = make([][3]float32, len(mesh.Triangles))
+    mesh.Colors = make([][3]mgl32.Vec4, len(mesh.Triangles))
+    mesh.Occlusion = make([][3]float32, len(mesh.Triangles))
+    surfaces := cropSurfaces(mesh, cubes)
+    if len(surfaces) != 1 || surfaces[0].Name != "iso 0" {
+        t.Fatalf("%v surfaces", len(surfaces))
+    }
+    // Two triangles per cube of the plane.
+    if len(surfaces[0].Triangles) != 2*40*5 || len(surfaces[0].Triangles) >= len(mesh.Triangles) {
+        t.Errorf("%v of %v triangles", len(surfaces[0].Triangles), len(mesh.Triangles))
+    }
+    for _,triangle := range surfaces[0].Triangles {
+        for _,v := range triangle {
+            for k := 0; k < 3; k++ {
+                if v[k] < bounds.Min[k] || v[k] > bounds.Max[k] {
+                    t.Fatalf("vertex %v outside of %v", v, bounds)
+                }
+            }
+        }
+    }
+}
+
+// The whole mesh command on the CPU, with the defaults of the viewer.
+func TestMeshCommandCpu(t *testing.T) {
+    dir, err := ioutil.TempDir("", "mcubes")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+    out := filepath.Join(dir, "mesh.glb")
+
+    if err := meshCommand([]string{"--cpu", "--bounds", "0,0,0,8,8,8", "--iso", "0,0.5", "--out", out}); err != nil {
+        t.Fatal(err)
+    }
+    glb, err := ioutil.ReadFile(out)
+    if err != nil {
+        t.Fatal(err)
+    }
+    document, _ := readGLB(t, glb)
+    if len(document.Meshes) == 0 {
+        t.Fatal("no meshes")
+    }
+    for _,mesh := range document.Meshes {
+        position := document.Accessors[mesh.Primitives[0].Attributes["POSITION"]]
+        for k := 0; k < 3; k++ {
+            if position.Min[k] < 0 || position.Max[k] > 8 {
+                t.Errorf("%v: from %v to %v", mesh.Name, position.Min, position.Max)
+            }
+        }
+    }
+
+    // Nothing is written, if there are no triangles.
+    empty := filepath.Join(dir, "empty.glb")
+    if err := meshCommand([]string{"--cpu", "--bounds", "0,100,0,8,108,8", "--out", empty}); err == nil {
+        t.Error("bounds without triangles are accepted")
+    }
+    if _, err := os.Stat(empty); !os.IsNotExist(err) {
+        t.Errorf("%v: %v", empty, err)
+    }
+}
+
+// Invalid resolutions fail before anything is meshed.
+func TestMeshCommandResolution(t *testing.T) {
+    out := filepath.Join(os.TempDir(), "mcubes-resolution.glb")
+    for _,resolution := range []string{"0", "-1", "NaN", "Inf", "-Inf", "1e40"} {
+        if err := meshCommand([]string{"--cpu", "--resolution", resolution, "--out", out}); err == nil {
+            t.Errorf("resolution %v is accepted", resolution)
+        }
+    }
+    if _, err := os.Stat(out); !os.IsNotExist(err) {
+        t.Errorf("%v: %v", out, err)
+    }
+}
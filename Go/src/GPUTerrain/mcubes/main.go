@@ -0,0 +1,313 @@
+// Command mcubes extracts the terrain of the viewer as mesh, without opening a window.
+//
+//    mcubes mesh --scene scene.json --bounds 0,0,0,150,10,150 --resolution 2 --iso 0 --out mesh.glb
+//
+// The extraction runs with the compute shader of the viewer in a headless context.
+// Without a GPU (or with --cpu), the CPU reference mesher is used instead.
+package main
+
+import (
+    . "GPUTerrain/Headless"
+    . "GPUTerrain/Mesher"
+    . "GPUTerrain/OpenGL"
+    . "GPUTerrain/Shaders"
+    . "GPUTerrain/Terrain"
+    "github.com/go-gl/gl/v4.5-core/gl"
+    "github.com/go-gl/mathgl/mgl32"
+    "flag"
+    "fmt"
+    "math"
+    "os"
+    "runtime"
+    "strconv"
+    "strings"
+)
+
+const (
+    // Cubes per unit. The grid is split into as many units, as the bounds need.
+    chunkSize = 32
+    workGroupSize = 8
+    // Room in the position buffer before it has to grow.
+    trianglesPerCube = 1.0
+    // Baked ambient occlusion like the viewer (BAKED_AO_RAYS and BAKED_AO_STEPS in marchingCubes.comp).
+    aoRays = 12
+    aoSteps = 6
+)
+
+func init() {
+    // The headless context has to stay on one OS thread.
+    runtime.LockOSThread()
+}
+
+func usage() {
+    fmt.Fprintln(os.Stderr, `usage: mcubes <command> [flags]
+
+commands:
+  mesh    extract the iso-surfaces of a scene and write them as .glb
+
+Run 'mcubes <command> -h' for the flags of a command.`)
+}
+
+func main() {
+    if len(os.Args) < 2 {
+        usage()
+        os.Exit(2)
+    }
+
+    var err error
+    switch os.Args[1] {
+        case "mesh":
+            err = meshCommand(os.Args[2:])
+        case "help", "-h", "-help", "--help":
+            usage()
+            return
+        default:
+            fmt.Fprintf(os.Stderr, "mcubes: unknown command %q\n", os.Args[1])
+            usage()
+            os.Exit(2)
+    }
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "mcubes:", err)
+        os.Exit(1)
+    }
+}
+
+// A comma separated list of finite floats. ParseFloat accepts "NaN" and "Inf", which no grid can be built from.
+func parseFloats(s string) ([]float32, error) {
+    var values []float32
+    for _,field := range strings.Split(s, ",") {
+        f, err := strconv.ParseFloat(strings.TrimSpace(field), 32)
+        if err != nil {
+            return nil, err
+        }
+        if math.IsNaN(f) || math.IsInf(f, 0) {
+            return nil, fmt.Errorf("%q is not a finite number", strings.TrimSpace(field))
+        }
+        values = append(values, float32(f))
+    }
+    return values, nil
+}
+
+// The axis aligned box, that is meshed, in world units.
+type Bounds struct {
+    Min, Max    mgl32.Vec3
+}
+
+func parseBounds(s string) (Bounds, error) {
+    values, err := parseFloats(s)
+    if err != nil {
+        return Bounds{}, fmt.Errorf("bounds: %v", err)
+    }
+    if len(values) != 6 {
+        return Bounds{}, fmt.Errorf("bounds have to be minX,minY,minZ,maxX,maxY,maxZ, not %q", s)
+    }
+    b := Bounds{mgl32.Vec3{values[0], values[1], values[2]}, mgl32.Vec3{values[3], values[4], values[5]}}
+    for k := 0; k < 3; k++ {
+        if b.Max[k] <= b.Min[k] {
+            return Bounds{}, fmt.Errorf("bounds %q are empty", s)
+        }
+    }
+    return b, nil
+}
+
+// Covers the bounds with units of chunkSize cubes. Returns the grid and the number of cubes inside of the bounds.
+// The last units stick out of the bounds, their outer cubes are cropped after the extraction.
+func boundsGrid(bounds Bounds, resolution float32, isoLevels []float32) (Grid, [3]int) {
+    grid := Grid {
+        ChunkSize: [3]int{chunkSize, chunkSize, chunkSize},
+        IsoLevels: isoLevels,
+        CubeSize:  1.0 / resolution,
+    }
+
+    var cubes, units [3]int
+    for k := 0; k < 3; k++ {
+        cubes[k] = int(math.Ceil(float64((bounds.Max[k] - bounds.Min[k]) * resolution)))
+        units[k] = (cubes[k] + chunkSize - 1) / chunkSize
+    }
+
+    // Unit offsets are in cubes.
+    origin := bounds.Min.Mul(resolution)
+    for z := 0; z < units[2]; z++ {
+        for y := 0; y < units[1]; y++ {
+            for x := 0; x < units[0]; x++ {
+                grid.UnitOffsets = append(grid.UnitOffsets, origin.Add(mgl32.Vec3{float32(x*chunkSize), float32(y*chunkSize), float32(z*chunkSize)}))
+            }
+        }
+    }
+    return grid, cubes
+}
+
+// Runs marchingCubes.comp in a headless context. Returns a noGpuError, if there is no context.
+func extractGpu(grid Grid, preset int, parameters *ParameterRegistry, shaderDir string) (*Mesh, error) {
+    if err := InitHeadlessContext(4, 3); err != nil {
+        return nil, noGpuError{err}
+    }
+    defer TerminateHeadlessContext()
+
+    defines := ShaderDefines {
+        "WORK_GROUP_SIZE_X":    workGroupSize,
+        "WORK_GROUP_SIZE_Y":    workGroupSize,
+        "WORK_GROUP_SIZE_Z":    workGroupSize,
+        "CHUNK_SIZE_X":         chunkSize,
+        "CHUNK_SIZE_Y":         chunkSize,
+        "CHUNK_SIZE_Z":         chunkSize,
+        "MAX_ISO_SURFACES":     len(grid.IsoLevels),
+        "HIGH_QUALITY_NORMALS": true,
+        "BAKED_AO_RAYS":        aoRays,
+    }
+    if shaderDir == "" {
+        ShaderFiles = EmbeddedShaders
+    } else if !strings.HasSuffix(shaderDir, "/") {
+        shaderDir += "/"
+    }
+    id, _, err := NewComputeProgramWithDefines(shaderDir+"marchingCubes.comp", defines)
+    if err != nil {
+        return nil, err
+    }
+    defer gl.DeleteProgram(id)
+    program := IntrospectProgram(id)
+
+    buffers := NewMarchingCubesBuffers(grid, [3]int{workGroupSize, workGroupSize, workGroupSize}, trianglesPerCube)
+    defer buffers.Delete()
+    if err := buffers.CheckDispatchLimits(); err != nil {
+        return nil, err
+    }
+
+    gl.UseProgram(program.ID)
+    defer gl.UseProgram(0)
+    if err := parameters.Upload(program); err != nil {
+        return nil, err
+    }
+    if err := program.SetInt("densityPreset", int32(preset)); err != nil {
+        return nil, err
+    }
+    if err := buffers.Extract(program); err != nil {
+        return nil, err
+    }
+    return buffers.ReadMesh(), nil
+}
+
+type noGpuError struct {
+    err error
+}
+
+func (e noGpuError) Error() string {
+    return e.err.Error()
+}
+
+// The triangles of every iso-surface, without the cubes outside of the bounds.
+func cropSurfaces(mesh *Mesh, cubes [3]int) []Surface {
+    grid := mesh.Grid
+    unitsX := (cubes[0] + chunkSize - 1) / chunkSize
+    unitsY := (cubes[1] + chunkSize - 1) / chunkSize
+
+    surfaces := make([]Surface, len(grid.IsoLevels))
+    for s,isoLevel := range grid.IsoLevels {
+        surfaces[s].Name = fmt.Sprintf("iso %v", isoLevel)
+        for u,_ := range grid.UnitOffsets {
+            unit := [3]int{u % unitsX, (u / unitsX) % unitsY, u / (unitsX*unitsY)}
+            for z := 0; z < grid.ChunkSize[2]; z++ {
+                for y := 0; y < grid.ChunkSize[1]; y++ {
+                    for x := 0; x < grid.ChunkSize[0]; x++ {
+                        index := [3]int{x, y, z}
+                        inside := true
+                        for k := 0; k < 3; k++ {
+                            inside = inside && unit[k]*chunkSize + index[k] < cubes[k]
+                        }
+                        if !inside {
+                            continue
+                        }
+                        first, end := mesh.CubeTriangles(grid.CubeIndex(x, y, z, u, s))
+                        surfaces[s].Triangles = append(surfaces[s].Triangles, mesh.Triangles[first:end]...)
+                        surfaces[s].Normals = append(surfaces[s].Normals, mesh.Normals[first:end]...)
+                        surfaces[s].MaterialIDs = append(surfaces[s].MaterialIDs, mesh.MaterialIDs[first:end]...)
+                        surfaces[s].Colors = append(surfaces[s].Colors, mesh.Colors[first:end]...)
+                        surfaces[s].Occlusion = append(surfaces[s].Occlusion, mesh.Occlusion[first:end]...)
+                    }
+                }
+            }
+        }
+    }
+    return surfaces
+}
+
+func meshCommand(args []string) error {
+    flags := flag.NewFlagSet("mesh", flag.ExitOnError)
+    sceneFile  := flags.String("scene", "", "JSON file with the density preset and its parameters (defaults of the viewer without)")
+    boundsFlag := flags.String("bounds", "0,0,0,150,10,150", "box to mesh in world units: minX,minY,minZ,maxX,maxY,maxZ")
+    resolution := flags.Float64("resolution", 1.0, "cubes per world unit")
+    isoFlag    := flags.String("iso", "0", "comma separated iso-levels, one mesh per level")
+    out        := flags.String("out", "mesh.glb", "output file (.glb)")
+    forceCpu   := flags.Bool("cpu", false, "always use the CPU mesher")
+    shaderDir  := flags.String("shaders", "", "directory of marchingCubes.comp (default: compiled in)")
+    flags.Parse(args)
+
+    if flags.NArg() > 0 {
+        return fmt.Errorf("unexpected arguments %v", flags.Args())
+    }
+    if !strings.HasSuffix(strings.ToLower(*out), ".glb") {
+        return fmt.Errorf("%v: only .glb is supported", *out)
+    }
+    bounds, err := parseBounds(*boundsFlag)
+    if err != nil {
+        return err
+    }
+    // The grid is in float32, so anything beyond its range is infinite as well. NaN fails every comparison.
+    if !(*resolution > 0) || math.IsInf(float64(float32(*resolution)), 0) {
+        return fmt.Errorf("resolution has to be positive and finite, not %v", *resolution)
+    }
+    isoLevels, err := parseFloats(*isoFlag)
+    if err != nil {
+        return fmt.Errorf("iso-levels: %v", err)
+    }
+
+    preset := 0
+    parameters := DeclareTerrainParameters()
+    if *sceneFile != "" {
+        if preset, parameters, err = loadScene(*sceneFile); err != nil {
+            return err
+        }
+    }
+
+    grid, cubes := boundsGrid(bounds, float32(*resolution), isoLevels)
+    fmt.Printf("%v: %vx%vx%v cubes in %v units, %v iso-levels\n", DensityPresets[preset], cubes[0], cubes[1], cubes[2], len(grid.UnitOffsets), len(isoLevels))
+
+    var mesh *Mesh
+    if !*forceCpu {
+        mesh, err = extractGpu(grid, preset, parameters, *shaderDir)
+        if _, noGpu := err.(noGpuError); noGpu {
+            fmt.Fprintln(os.Stderr, "no GPU, using the CPU mesher:", err)
+        } else if err != nil {
+            return err
+        }
+    }
+    if mesh == nil {
+        density := CpuDensity(preset, parameters)
+        if mesh, err = Extract(grid, density, ShippedTables); err != nil {
+            return err
+        }
+        mesh.CalculateNormals(density)
+        mesh.CalculateMaterials(CpuMaterials(parameters))
+        aoDistance, err := parameters.Get("aoDistance")
+        if err != nil {
+            return err
+        }
+        mesh.CalculateOcclusion(density, aoRays, aoSteps, aoDistance.Value[0])
+    }
+
+    surfaces := cropSurfaces(mesh, cubes)
+    triangles := 0
+    for _,surface := range surfaces {
+        fmt.Printf("%v: %v triangles\n", surface.Name, len(surface.Triangles))
+        triangles += len(surface.Triangles)
+    }
+    // An empty file would only be noticed, when it is opened.
+    if triangles == 0 {
+        return fmt.Errorf("no triangles in bounds %q at the iso-levels %v", *boundsFlag, isoLevels)
+    }
+    if err := SaveGLB(*out, surfaces); err != nil {
+        return err
+    }
+    fmt.Println("saved", *out)
+    return nil
+}
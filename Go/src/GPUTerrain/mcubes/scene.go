@@ -0,0 +1,72 @@
+package main
+
+import (
+    . "GPUTerrain/OpenGL"
+    . "GPUTerrain/Terrain"
+    "github.com/go-gl/mathgl/mgl32"
+    "encoding/json"
+    "fmt"
+    "os"
+)
+
+// The density function to mesh. A scene file looks like
+//
+//    {
+//        "preset": "terrain",
+//        "parameters": {"hillHeight": 6, "noiseScale": [0.03, 0.06, 0.03]}
+//    }
+//
+// Parameters, that are not given, keep the defaults of the viewer (see DeclareTerrainParameters).
+type SceneFile struct {
+    Preset      string                      `json:"preset"`
+    Parameters  map[string]json.RawMessage  `json:"parameters"`
+}
+
+// Loads the scene and returns the preset index and all parameters with the values of the scene.
+func loadScene(fileName string) (int, *ParameterRegistry, error) {
+    parameters := DeclareTerrainParameters()
+
+    file, err := os.Open(fileName)
+    if err != nil {
+        return 0, nil, err
+    }
+    defer file.Close()
+
+    var scene SceneFile
+    decoder := json.NewDecoder(file)
+    decoder.DisallowUnknownFields()
+    if err := decoder.Decode(&scene); err != nil {
+        return 0, nil, fmt.Errorf("%v: %v", fileName, err)
+    }
+
+    preset := 0
+    if scene.Preset != "" {
+        if preset, err = DensityPresetIndex(scene.Preset); err != nil {
+            return 0, nil, fmt.Errorf("%v: %v", fileName, err)
+        }
+    }
+
+    for name,raw := range scene.Parameters {
+        p, err := parameters.Get(name)
+        if err != nil {
+            return 0, nil, fmt.Errorf("%v: %v", fileName, err)
+        }
+        switch p.Type {
+            case FloatParameter:
+                var value float32
+                if err = json.Unmarshal(raw, &value); err == nil {
+                    err = parameters.SetFloat(name, value)
+                }
+            case Vec3Parameter:
+                var value mgl32.Vec3
+                if err = json.Unmarshal(raw, &value); err == nil {
+                    err = parameters.SetVec3(name, value)
+                }
+        }
+        if err != nil {
+            return 0, nil, fmt.Errorf("%v: parameter %v: %v", fileName, name, err)
+        }
+    }
+
+    return preset, parameters, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+    . "GPUTerrain/Mesher"
+    . "GPUTerrain/Terrain"
+    "fmt"
+    "time"
+)
+
+// Maximum distance of a GPU vertex from the CPU vertex in cubes. The GPU sin/exp and the noise
+// are only close to the CPU versions, so the interpolation along the edge differs slightly.
+const g_meshTestTolerance = 0.01
+
+// Checks the tables against the generated ones, then extracts every density preset with the compute shader
+// and compares the cases, the layout sizes after the prefix sum, the triangles per unit and all vertices
+// against the CPU mesher.
+// Prints its progress, since one preset can take minutes with a software renderer.
+// Returns, if all presets passed.
+func runMeshTest() bool {
+    // The CPU reference uses the same tables as the GPU, so a typo in them would not show up below.
+    if !runTableCheck() {
+        return false
+    }
+
+    grid := g_marchingCubes.Grid
+    passed := 0
+    for preset,name := range DensityPresets {
+        fmt.Printf("preset %v/%v %v: extracting on the GPU\n", preset+1, len(DensityPresets), name)
+        start := time.Now()
+        setDensityPreset(preset)
+        if needsRecalculation() {
+            calculateMarchingCubes()
+        }
+        gpu := g_marchingCubes.ReadMesh()
+        fmt.Printf("preset %v/%v %v: %v triangles in %v, comparing with the CPU\n", preset+1, len(DensityPresets), name, len(gpu.Triangles), time.Since(start))
+
+        start = time.Now()
+        field := Sample(grid, CpuDensity(preset, g_parameters))
+        result, err := Compare(field, gpu, ShippedTables, g_meshTestTolerance)
+        fmt.Printf("preset %v/%v %v: compared in %v\n", preset+1, len(DensityPresets), name, time.Since(start))
+        if err != nil {
+            fmt.Println("FAIL", name+":", err)
+            continue
+        }
+        if !result.Passed {
+            fmt.Println("FAIL", name+":", result)
+            if len(result.UnitMismatches) > 0 {
+                fmt.Println("     units:", result.UnitMismatches)
+            }
+            continue
+        }
+        fmt.Println("ok  ", name+":", result)
+        passed += 1
+    }
+
+    fmt.Printf("%v of %v presets passed\n", passed, len(DensityPresets))
+    return passed == len(DensityPresets)
+}
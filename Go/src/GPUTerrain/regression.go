@@ -0,0 +1,112 @@
+package main
+
+import (
+    . "GPUTerrain/Golden"
+    . "GPUTerrain/OpenGL"
+    "github.com/go-gl/mathgl/mgl32"
+    "fmt"
+    "image"
+    "os"
+    "path/filepath"
+)
+
+// A fixed view for the golden image regression test.
+type Scene struct {
+    Name        string
+    // Index into DensityPresets.
+    Preset      int
+    // See applyFillMode.
+    FillMode    int
+    Eye         mgl32.Vec3
+    Center      mgl32.Vec3
+}
+
+// Every scene is compared against <g_goldenDir>/<Name>.png.
+var g_regressionScenes = []Scene {
+    Scene{Name: "terrain",           Preset: 0, FillMode: 0, Eye: mgl32.Vec3{-20,45,-20}, Center: mgl32.Vec3{80,5,80}},
+    Scene{Name: "terrain_wireframe", Preset: 0, FillMode: 1, Eye: mgl32.Vec3{30,18,30},   Center: mgl32.Vec3{45,6,45}},
+    Scene{Name: "terrain_points",    Preset: 0, FillMode: 2, Eye: mgl32.Vec3{30,18,30},   Center: mgl32.Vec3{45,6,45}},
+    Scene{Name: "csg",               Preset: 1, FillMode: 0, Eye: mgl32.Vec3{15,12,17},   Center: mgl32.Vec3{5,5,5}},
+    Scene{Name: "gyroid",            Preset: 2, FillMode: 0, Eye: mgl32.Vec3{-10,25,-10}, Center: mgl32.Vec3{30,5,30}},
+    Scene{Name: "waves",             Preset: 3, FillMode: 0, Eye: mgl32.Vec3{-20,40,-20}, Center: mgl32.Vec3{80,5,80}},
+}
+
+func renderScene(scene Scene) {
+    setDensityPreset(scene.Preset)
+    g_fillMode = scene.FillMode
+    applyFillMode()
+    g_camera.SetLookAt(scene.Eye, scene.Center, mgl32.Vec3{0,1,0})
+
+    calculateAndRenderMarchingCubes()
+}
+
+// Renders all scenes and compares them against the golden images.
+// Failed scenes leave their render and a diff image in g_diffDir. Returns, if all scenes passed.
+func runRegression() bool {
+    if *g_updateGolden {
+        if err := os.MkdirAll(*g_goldenDir, 0755); err != nil {
+            fmt.Println(err)
+            return false
+        }
+    }
+
+    passed := 0
+    for _,scene := range g_regressionScenes {
+        renderScene(scene)
+        render := ReadFramebuffer(g_outputFbo, g_windowWidth, g_windowHeight)
+        goldenFile := filepath.Join(*g_goldenDir, scene.Name+".png")
+
+        if *g_updateGolden {
+            if err := SavePNG(goldenFile, render); err != nil {
+                fmt.Println("FAIL", scene.Name+":", err)
+                continue
+            }
+            fmt.Println("updated", goldenFile)
+            passed += 1
+            continue
+        }
+
+        golden, err := LoadPNG(goldenFile)
+        if err != nil {
+            fmt.Println("FAIL", scene.Name+":", err)
+            saveFailure(scene, render, nil)
+            continue
+        }
+        result, err := Compare(golden, render, DefaultTolerance)
+        if err != nil {
+            fmt.Println("FAIL", scene.Name+":", err)
+            saveFailure(scene, render, nil)
+            continue
+        }
+        if !result.Passed {
+            fmt.Println("FAIL", scene.Name+":", result)
+            saveFailure(scene, render, &result)
+            continue
+        }
+        fmt.Println("ok  ", scene.Name+":", result)
+        passed += 1
+    }
+
+    fmt.Printf("%v of %v scenes passed\n", passed, len(g_regressionScenes))
+    return passed == len(g_regressionScenes)
+}
+
+func saveFailure(scene Scene, render *image.RGBA, result *Result) {
+    if err := os.MkdirAll(*g_diffDir, 0755); err != nil {
+        fmt.Println(err)
+        return
+    }
+    renderFile := filepath.Join(*g_diffDir, scene.Name+".png")
+    if err := SavePNG(renderFile, render); err != nil {
+        fmt.Println(err)
+    }
+    if result == nil {
+        return
+    }
+    diffFile := filepath.Join(*g_diffDir, scene.Name+"_diff.png")
+    if err := SavePNG(diffFile, result.Diff); err != nil {
+        fmt.Println(err)
+        return
+    }
+    fmt.Println("     diff:", diffFile)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+    . "GPUTerrain/Mesher"
+    "fmt"
+)
+
+// Generates the tables from the topology of the compute shader and compares the shipped ones against them.
+// Needs no OpenGL context. Returns, if the shipped tables match.
+func runTableCheck() bool {
+    generated, err := GenerateTables(ShaderTopology, SeparateSolid)
+    if err != nil {
+        fmt.Println("FAIL generating the tables:", err)
+        return false
+    }
+
+    differences := CompareTables(generated, ShippedTables)
+    for _,difference := range differences {
+        fmt.Println("FAIL", difference)
+    }
+    if len(differences) > 0 {
+        fmt.Printf("%v of 256 cases differ from the generated tables\n", len(differences))
+        return false
+    }
+    fmt.Println("ok   all 256 cases match the generated tables")
+    return true
+}
@@ -20,6 +20,7 @@ go get "github.com/MauriceGit/half"
 
 echo "Build Task"
 go install GPUTerrain
+go install GPUTerrain/mcubes
 
 
 